@@ -0,0 +1,145 @@
+package display
+
+import (
+	"errors"
+)
+
+/*
+ *	Container
+ */
+type Container struct {
+	DisplayObject
+	children []Object
+}
+
+func NewContainer() *Container {
+	c := &Container{}
+	c.init()
+	return c
+}
+
+func (c *Container) Children() []Object {
+	return c.children
+}
+
+func (c *Container) NumChildren() int {
+	return len(c.children)
+}
+
+func (c *Container) ChildAt(index int) Object {
+	return c.children[index]
+}
+
+// ChildIndex 返回子节点的位置，不是子节点时返回 -1
+func (c *Container) ChildIndex(child Object) int {
+	for i, o := range c.children {
+		if o.displayObject() == child.displayObject() {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddChild 添加到最上层，子节点已经有父节点时先从原来的父节点移除
+func (c *Container) AddChild(child Object) {
+	c.AddChildAt(child, len(c.children))
+}
+
+// AddChildAt 插入到 index 处，index 按插入前的子节点计算
+func (c *Container) AddChildAt(child Object, index int) {
+	if index < 0 || index > len(c.children) {
+		panic(errors.New("add child: index out of range"))
+	}
+	o := child.displayObject()
+	for p := c; p != nil; p = p.parent {
+		if &p.DisplayObject == o {
+			panic(errors.New("add child: cannot add a container to itself or its descendants"))
+		}
+	}
+
+	if o.parent == c {
+		//已经是子节点时先移除，index 是按移除前的位置计算的
+		if c.ChildIndex(child) < index {
+			index--
+		}
+		c.RemoveChild(child)
+	} else if o.parent != nil {
+		o.parent.RemoveChild(child)
+	}
+	o.parent = c
+	o.worldDirty = true
+
+	c.children = append(c.children, nil)
+	copy(c.children[index+1:], c.children[index:])
+	c.children[index] = child
+}
+
+// RemoveChild 不是子节点时返回 false
+func (c *Container) RemoveChild(child Object) bool {
+	index := c.ChildIndex(child)
+	if index < 0 {
+		return false
+	}
+	c.RemoveChildAt(index)
+	return true
+}
+
+func (c *Container) RemoveChildAt(index int) Object {
+	child := c.children[index]
+	copy(c.children[index:], c.children[index+1:])
+	c.children[len(c.children)-1] = nil
+	c.children = c.children[:len(c.children)-1]
+
+	o := child.displayObject()
+	o.parent = nil
+	o.worldDirty = true
+	return child
+}
+
+func (c *Container) RemoveChildren() {
+	for _, child := range c.children {
+		o := child.displayObject()
+		o.parent = nil
+		o.worldDirty = true
+	}
+	c.children = nil
+}
+
+// SetChildIndex 移动子节点的位置，越靠后绘制时越靠上
+func (c *Container) SetChildIndex(child Object, index int) {
+	current := c.ChildIndex(child)
+	if current < 0 {
+		panic(errors.New("set child index: not a child"))
+	}
+	if index < 0 || index >= len(c.children) {
+		panic(errors.New("set child index: index out of range"))
+	}
+	if current < index {
+		copy(c.children[current:], c.children[current+1:index+1])
+	} else {
+		copy(c.children[index+1:], c.children[index:current])
+	}
+	c.children[index] = child
+}
+
+func (c *Container) SwapChildren(a, b Object) {
+	i, j := c.ChildIndex(a), c.ChildIndex(b)
+	if i < 0 || j < 0 {
+		panic(errors.New("swap children: not a child"))
+	}
+	c.children[i], c.children[j] = c.children[j], c.children[i]
+}
+
+// UpdateTransform 更新自己和所有可见子节点的变换，通常在每帧绘制前对根节点调用
+func (c *Container) UpdateTransform() {
+	c.updateTransform(c.parentObject())
+}
+
+func (c *Container) updateTransform(parent *DisplayObject) {
+	c.DisplayObject.updateTransform(parent)
+	for _, child := range c.children {
+		if child.displayObject().visible {
+			child.updateTransform(&c.DisplayObject)
+		}
+	}
+}
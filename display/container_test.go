@@ -0,0 +1,176 @@
+package display
+
+import (
+	stdmath "math"
+	"testing"
+)
+
+func names(c *Container, objs map[Object]string) string {
+	s := ""
+	for _, child := range c.Children() {
+		s += objs[child]
+	}
+	return s
+}
+
+func newTree() (*Container, *DisplayObject, *DisplayObject, *DisplayObject, map[Object]string) {
+	c := NewContainer()
+	a, b, d := NewDisplayObject(), NewDisplayObject(), NewDisplayObject()
+	c.AddChild(a)
+	c.AddChild(b)
+	c.AddChild(d)
+	return c, a, b, d, map[Object]string{a: "a", b: "b", d: "d"}
+}
+
+func TestAddChild(t *testing.T) {
+	c, a, b, _, objs := newTree()
+	if got := names(c, objs); got != "abd" {
+		t.Fatalf("children = %q, want abd", got)
+	}
+	if a.Parent() != c || b.Parent() != c {
+		t.Fatal("parent not set")
+	}
+
+	//重复添加移动到最上层
+	c.AddChild(a)
+	if got := names(c, objs); got != "bda" {
+		t.Fatalf("re-add: children = %q, want bda", got)
+	}
+	c.AddChild(a)
+	if got := names(c, objs); got != "bda" {
+		t.Fatalf("re-add top: children = %q, want bda", got)
+	}
+
+	//index 按插入前的子节点计算
+	c.AddChildAt(b, 2)
+	if got := names(c, objs); got != "dba" {
+		t.Fatalf("AddChildAt(b, 2): children = %q, want dba", got)
+	}
+	c.AddChildAt(a, 0)
+	if got := names(c, objs); got != "adb" {
+		t.Fatalf("AddChildAt(a, 0): children = %q, want adb", got)
+	}
+
+	//从另一个父节点移过来
+	other := NewContainer()
+	other.AddChild(a)
+	if got := names(c, objs); got != "db" || a.Parent() != other || other.NumChildren() != 1 {
+		t.Fatalf("move: children = %q, parent ok = %v", got, a.Parent() == other)
+	}
+}
+
+func TestAddChildCycle(t *testing.T) {
+	parent, child := NewContainer(), NewContainer()
+	parent.AddChild(child)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("adding an ancestor did not panic")
+		}
+	}()
+	child.AddChild(parent)
+}
+
+func TestRemoveChild(t *testing.T) {
+	c, a, b, d, objs := newTree()
+	if !c.RemoveChild(b) {
+		t.Fatal("RemoveChild(b) = false")
+	}
+	if c.RemoveChild(b) {
+		t.Fatal("RemoveChild on a removed child = true")
+	}
+	if got := names(c, objs); got != "ad" || b.Parent() != nil {
+		t.Fatalf("children = %q, parent = %v", got, b.Parent())
+	}
+	if c.RemoveChildAt(0) != Object(a) {
+		t.Fatal("RemoveChildAt(0) returned the wrong child")
+	}
+	c.RemoveChildren()
+	if c.NumChildren() != 0 || d.Parent() != nil {
+		t.Fatal("RemoveChildren left children behind")
+	}
+}
+
+func TestSetChildIndex(t *testing.T) {
+	c, a, b, d, objs := newTree()
+	c.SetChildIndex(a, 2)
+	if got := names(c, objs); got != "bda" {
+		t.Fatalf("SetChildIndex(a, 2): children = %q, want bda", got)
+	}
+	c.SetChildIndex(a, 0)
+	if got := names(c, objs); got != "abd" {
+		t.Fatalf("SetChildIndex(a, 0): children = %q, want abd", got)
+	}
+	c.SwapChildren(a, d)
+	if got := names(c, objs); got != "dba" || c.ChildIndex(b) != 1 {
+		t.Fatalf("SwapChildren: children = %q, want dba", got)
+	}
+}
+
+func near(a, b float32) bool {
+	return stdmath.Abs(float64(a-b)) < 1e-4
+}
+
+func TestWorldTransform(t *testing.T) {
+	parent := NewContainer()
+	child := NewContainer()
+	leaf := NewDisplayObject()
+	parent.AddChild(child)
+	child.AddChild(leaf)
+
+	parent.SetPosition(100, 50)
+	parent.SetScale(2, 2)
+	child.SetPosition(10, 0)
+	child.SetRotation(stdmath.Pi / 2)
+	leaf.SetPosition(5, 0)
+	leaf.SetAlpha(0.5)
+	parent.SetAlpha(0.5)
+	parent.UpdateTransform()
+
+	//leaf 原点：旋转 90 度后 (5, 0) -> (0, 5)，加上 (10, 0)，再放大两倍并平移
+	x, y := leaf.ToGlobal(0, 0)
+	if !near(x, 120) || !near(y, 60) {
+		t.Fatalf("leaf origin = (%v, %v), want (120, 60)", x, y)
+	}
+	lx, ly := leaf.ToLocal(x, y)
+	if !near(lx, 0) || !near(ly, 0) {
+		t.Fatalf("ToLocal = (%v, %v), want (0, 0)", lx, ly)
+	}
+	if !near(leaf.WorldAlpha(), 0.25) {
+		t.Fatalf("leaf world alpha = %v, want 0.25", leaf.WorldAlpha())
+	}
+
+	//没有变化时不重新计算世界矩阵
+	id := leaf.worldID
+	parent.UpdateTransform()
+	if leaf.worldID != id {
+		t.Fatal("world transform recomputed without changes")
+	}
+
+	//父节点变化会传递给子节点
+	parent.SetPosition(0, 0)
+	parent.UpdateTransform()
+	if leaf.worldID == id {
+		t.Fatal("parent change did not propagate")
+	}
+	x, y = leaf.ToGlobal(0, 0)
+	if !near(x, 20) || !near(y, 10) {
+		t.Fatalf("leaf origin = (%v, %v), want (20, 10)", x, y)
+	}
+
+	//换父节点后使用新父节点的矩阵
+	parent.AddChild(leaf)
+	parent.UpdateTransform()
+	x, y = leaf.ToGlobal(0, 0)
+	if !near(x, 10) || !near(y, 0) {
+		t.Fatalf("reparented leaf origin = (%v, %v), want (10, 0)", x, y)
+	}
+
+	//不可见的节点不更新
+	leaf.SetVisible(false)
+	leaf.SetPosition(50, 0)
+	parent.UpdateTransform()
+	x, _ = leaf.ToGlobal(0, 0)
+	if !near(x, 10) {
+		t.Fatalf("invisible leaf updated: x = %v", x)
+	}
+}
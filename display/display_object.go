@@ -0,0 +1,189 @@
+package display
+
+import (
+	"github.com/jangsky215/pixi/math"
+)
+
+// Object 是场景图中的节点，嵌入 DisplayObject 或 Container 的类型都满足这个接口
+type Object interface {
+	displayObject() *DisplayObject
+	updateTransform(parent *DisplayObject)
+}
+
+/*
+ *	DisplayObject
+ */
+type DisplayObject struct {
+	x, y           float32
+	pivotX, pivotY float32
+	scaleX, scaleY float32
+	rotation       float32
+	skewX, skewY   float32
+	alpha          float32
+	visible        bool
+
+	parent *Container
+
+	localTransform math.Matrix
+	worldTransform math.Matrix
+	worldAlpha     float32
+
+	localDirty bool //位置、缩放等改变后需要重新计算本地矩阵
+	worldDirty bool //本地矩阵改变或换了父节点后需要重新计算世界矩阵
+	worldID    uint32
+	parentID   uint32 //上次计算世界矩阵时父节点的 worldID
+}
+
+var identity = func() math.Matrix {
+	var m math.Matrix
+	m.Identity()
+	return m
+}()
+
+// 根节点的父节点
+var root = DisplayObject{worldTransform: identity, worldAlpha: 1}
+
+func (o *DisplayObject) init() {
+	o.scaleX, o.scaleY = 1, 1
+	o.alpha = 1
+	o.visible = true
+	o.localTransform = identity
+	o.worldTransform = identity
+	o.worldAlpha = 1
+	o.worldDirty = true
+}
+
+func NewDisplayObject() *DisplayObject {
+	o := &DisplayObject{}
+	o.init()
+	return o
+}
+
+func (o *DisplayObject) displayObject() *DisplayObject {
+	return o
+}
+
+func (o *DisplayObject) SetPosition(x, y float32) {
+	o.x, o.y = x, y
+	o.localDirty = true
+}
+
+func (o *DisplayObject) Position() (float32, float32) {
+	return o.x, o.y
+}
+
+func (o *DisplayObject) SetScale(x, y float32) {
+	o.scaleX, o.scaleY = x, y
+	o.localDirty = true
+}
+
+func (o *DisplayObject) Scale() (float32, float32) {
+	return o.scaleX, o.scaleY
+}
+
+// SetPivot 设置旋转和缩放的中心点，使用本地坐标
+func (o *DisplayObject) SetPivot(x, y float32) {
+	o.pivotX, o.pivotY = x, y
+	o.localDirty = true
+}
+
+func (o *DisplayObject) Pivot() (float32, float32) {
+	return o.pivotX, o.pivotY
+}
+
+// SetRotation 设置旋转角度，单位为弧度
+func (o *DisplayObject) SetRotation(radian float32) {
+	o.rotation = radian
+	o.localDirty = true
+}
+
+func (o *DisplayObject) Rotation() float32 {
+	return o.rotation
+}
+
+// SetSkew 设置扭曲，单位为弧度
+func (o *DisplayObject) SetSkew(x, y float32) {
+	o.skewX, o.skewY = x, y
+	o.localDirty = true
+}
+
+func (o *DisplayObject) Skew() (float32, float32) {
+	return o.skewX, o.skewY
+}
+
+func (o *DisplayObject) SetAlpha(alpha float32) {
+	o.alpha = alpha
+}
+
+func (o *DisplayObject) Alpha() float32 {
+	return o.alpha
+}
+
+// SetVisible 不可见的节点和它的子节点不会更新变换
+func (o *DisplayObject) SetVisible(visible bool) {
+	o.visible = visible
+}
+
+func (o *DisplayObject) Visible() bool {
+	return o.visible
+}
+
+func (o *DisplayObject) Parent() *Container {
+	return o.parent
+}
+
+// LocalTransform 返回最近一次 UpdateTransform 计算的本地矩阵，不要修改
+func (o *DisplayObject) LocalTransform() *math.Matrix {
+	return &o.localTransform
+}
+
+// WorldTransform 返回最近一次 UpdateTransform 计算的世界矩阵，不要修改
+func (o *DisplayObject) WorldTransform() *math.Matrix {
+	return &o.worldTransform
+}
+
+// WorldAlpha 返回乘上所有父节点 alpha 之后的值
+func (o *DisplayObject) WorldAlpha() float32 {
+	return o.worldAlpha
+}
+
+// ToGlobal 把本地坐标转换成世界坐标
+func (o *DisplayObject) ToGlobal(x, y float32) (float32, float32) {
+	return o.worldTransform.Apply(x, y)
+}
+
+// ToLocal 把世界坐标转换成本地坐标
+func (o *DisplayObject) ToLocal(x, y float32) (float32, float32) {
+	return o.worldTransform.ApplyInverse(x, y)
+}
+
+// UpdateTransform 根据父节点当前的世界矩阵更新自己的变换，父节点需要已经更新过
+func (o *DisplayObject) UpdateTransform() {
+	o.updateTransform(o.parentObject())
+}
+
+func (o *DisplayObject) parentObject() *DisplayObject {
+	if o.parent == nil {
+		return &root
+	}
+	return &o.parent.DisplayObject
+}
+
+func (o *DisplayObject) updateTransform(parent *DisplayObject) {
+	if o.localDirty {
+		o.localDirty = false
+		o.localTransform.SetTransform(o.x, o.y, o.pivotX, o.pivotY, o.scaleX, o.scaleY, o.rotation, o.skewX, o.skewY)
+		o.worldDirty = true
+	}
+
+	//本地矩阵和父节点都没有变化时保留上次的世界矩阵
+	if o.worldDirty || o.parentID != parent.worldID {
+		o.worldDirty = false
+		o.parentID = parent.worldID
+		o.worldTransform = parent.worldTransform
+		o.worldTransform.Append(&o.localTransform)
+		o.worldID++
+	}
+
+	o.worldAlpha = o.alpha * parent.worldAlpha
+}
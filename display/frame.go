@@ -0,0 +1,68 @@
+package display
+
+import (
+	"image"
+
+	"github.com/jangsky215/pixi/internal"
+)
+
+/*
+ *	Frame
+ */
+
+// Frame 是纹理中的一块区域，坐标单位为像素，和图集导出工具（如 TexturePacker）的字段对应：
+// Rect 为区域在纹理中的位置，Rotated 为 true 时区域在纹理中顺时针旋转了 90 度，Rect 的宽高与原图相反；
+// Trim 为裁掉透明边之后的内容在原图中的位置，为空表示没有裁剪；SourceWidth、SourceHeight 为裁剪前原图的大小
+type Frame struct {
+	Texture      *internal.Texture
+	Rect         image.Rectangle
+	Rotated      bool
+	Trim         image.Rectangle
+	SourceWidth  int
+	SourceHeight int
+}
+
+// NewFrame 创建没有裁剪和旋转的区域
+func NewFrame(tex *internal.Texture, rect image.Rectangle) *Frame {
+	return &Frame{
+		Texture:      tex,
+		Rect:         rect,
+		SourceWidth:  rect.Dx(),
+		SourceHeight: rect.Dy(),
+	}
+}
+
+// NewTextureFrame 创建覆盖整张纹理的区域
+func NewTextureFrame(tex *internal.Texture) *Frame {
+	if tex == nil {
+		return NewFrame(nil, image.Rectangle{})
+	}
+	return NewFrame(tex, image.Rect(0, 0, tex.Width(), tex.Height()))
+}
+
+// UVs 返回原图左上、右上、右下、左下四个角的纹理坐标，已经处理了旋转；没有纹理或纹理为空时都为 0
+func (f *Frame) UVs() [4][2]float32 {
+	if f.Texture == nil || f.Texture.Width() == 0 || f.Texture.Height() == 0 {
+		return [4][2]float32{}
+	}
+	return f.uvs(float32(f.Texture.Width()), float32(f.Texture.Height()))
+}
+
+func (f *Frame) uvs(w, h float32) [4][2]float32 {
+	x0, y0 := float32(f.Rect.Min.X)/w, float32(f.Rect.Min.Y)/h
+	x1, y1 := float32(f.Rect.Max.X)/w, float32(f.Rect.Max.Y)/h
+
+	if f.Rotated {
+		//原图的左上角在纹理中位于区域的右上角
+		return [4][2]float32{{x1, y0}, {x1, y1}, {x0, y1}, {x0, y0}}
+	}
+	return [4][2]float32{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+}
+
+// 内容在原图坐标中的范围
+func (f *Frame) content() (x0, y0, x1, y1 float32) {
+	if f.Trim.Empty() {
+		return 0, 0, float32(f.SourceWidth), float32(f.SourceHeight)
+	}
+	return float32(f.Trim.Min.X), float32(f.Trim.Min.Y), float32(f.Trim.Max.X), float32(f.Trim.Max.Y)
+}
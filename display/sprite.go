@@ -0,0 +1,155 @@
+package display
+
+import (
+	"github.com/jangsky215/pixi/internal"
+)
+
+// Vertex 是 Sprite 输出的顶点，颜色为 tint 乘上 WorldAlpha，纹理是预乘 alpha 时颜色也是预乘的
+type Vertex struct {
+	X, Y       float32
+	U, V       float32
+	R, G, B, A float32
+}
+
+// QuadIndices 是 Vertices 返回的四个顶点组成两个三角形的索引
+var QuadIndices = []uint16{
+	0, 1, 2,
+	0, 2, 3,
+}
+
+/*
+ *	Sprite
+ */
+type Sprite struct {
+	DisplayObject
+	frame            *Frame
+	anchorX, anchorY float32
+	tint             uint32
+
+	vertices      [4]Vertex
+	vertexDirty   bool   //frame 或 anchor 改变后需要重新计算顶点
+	vertexWorldID uint32 //上次计算顶点时的 worldID
+}
+
+func NewSprite(frame *Frame) *Sprite {
+	s := &Sprite{
+		frame:       frame,
+		tint:        0xFFFFFF,
+		vertexDirty: true,
+	}
+	s.init()
+	return s
+}
+
+// NewSpriteFromTexture 创建显示整张纹理的 Sprite
+func NewSpriteFromTexture(tex *internal.Texture) *Sprite {
+	return NewSprite(NewTextureFrame(tex))
+}
+
+func (s *Sprite) SetFrame(frame *Frame) {
+	s.frame = frame
+	s.vertexDirty = true
+}
+
+func (s *Sprite) Frame() *Frame {
+	return s.frame
+}
+
+func (s *Sprite) Texture() *internal.Texture {
+	if s.frame == nil {
+		return nil
+	}
+	return s.frame.Texture
+}
+
+// SetAnchor 设置锚点，按原图大小的比例计算，(0.5, 0.5) 为中心；锚点位于 Position 处
+func (s *Sprite) SetAnchor(x, y float32) {
+	s.anchorX, s.anchorY = x, y
+	s.vertexDirty = true
+}
+
+func (s *Sprite) Anchor() (float32, float32) {
+	return s.anchorX, s.anchorY
+}
+
+// SetTint 设置颜色，格式为 0xRRGGBB，0xFFFFFF 表示不改变纹理的颜色
+func (s *Sprite) SetTint(rgb uint32) {
+	s.tint = rgb & 0xFFFFFF
+}
+
+func (s *Sprite) Tint() uint32 {
+	return s.tint
+}
+
+// Width 返回原图宽度乘上缩放
+func (s *Sprite) Width() float32 {
+	if s.frame == nil {
+		return 0
+	}
+	return float32(s.frame.SourceWidth) * abs(s.scaleX)
+}
+
+func (s *Sprite) Height() float32 {
+	if s.frame == nil {
+		return 0
+	}
+	return float32(s.frame.SourceHeight) * abs(s.scaleY)
+}
+
+// Vertices 返回左上、右上、右下、左下四个顶点的世界坐标、纹理坐标和颜色，
+// 需要先对场景的根节点调用 UpdateTransform；变换和 frame 都没有变化时不会重新计算坐标
+func (s *Sprite) Vertices() [4]Vertex {
+	if s.frame == nil {
+		return [4]Vertex{}
+	}
+
+	if s.vertexDirty || s.vertexWorldID != s.worldID {
+		s.vertexDirty = false
+		s.vertexWorldID = s.worldID
+		s.calculateVertices()
+	}
+
+	r, g, b, a := s.color()
+	for i := range s.vertices {
+		v := &s.vertices[i]
+		v.R, v.G, v.B, v.A = r, g, b, a
+	}
+	return s.vertices
+}
+
+func (s *Sprite) calculateVertices() {
+	f := s.frame
+	ax := s.anchorX * float32(f.SourceWidth)
+	ay := s.anchorY * float32(f.SourceHeight)
+	x0, y0, x1, y1 := f.content()
+	x0, x1 = x0-ax, x1-ax
+	y0, y1 = y0-ay, y1-ay
+
+	corners := [4][2]float32{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+	uvs := f.UVs()
+	m := &s.worldTransform
+	for i, p := range corners {
+		v := &s.vertices[i]
+		v.X, v.Y = m.Apply(p[0], p[1])
+		v.U, v.V = uvs[i][0], uvs[i][1]
+	}
+}
+
+func (s *Sprite) color() (float32, float32, float32, float32) {
+	a := s.worldAlpha
+	r := float32(s.tint>>16&0xFF) / 255
+	g := float32(s.tint>>8&0xFF) / 255
+	b := float32(s.tint&0xFF) / 255
+	//没有纹理时按默认的预乘 alpha 处理
+	if s.frame.Texture == nil || s.frame.Texture.AlphaMode() == internal.AlphaPremultiplied {
+		r, g, b = r*a, g*a, b*a
+	}
+	return r, g, b, a
+}
+
+func abs(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,103 @@
+package display
+
+import (
+	"image"
+	"testing"
+)
+
+func checkVertices(t *testing.T, name string, s *Sprite, want [4][2]float32) {
+	t.Helper()
+	vs := s.Vertices()
+	for i, v := range vs {
+		if !near(v.X, want[i][0]) || !near(v.Y, want[i][1]) {
+			t.Errorf("%s: vertex %d = (%v, %v), want (%v, %v)", name, i, v.X, v.Y, want[i][0], want[i][1])
+		}
+	}
+}
+
+func TestSpriteAnchor(t *testing.T) {
+	s := NewSprite(NewFrame(nil, image.Rect(0, 0, 100, 50)))
+	s.SetPosition(10, 20)
+	s.UpdateTransform()
+	checkVertices(t, "no anchor", s, [4][2]float32{{10, 20}, {110, 20}, {110, 70}, {10, 70}})
+
+	//锚点位于 Position 处
+	s.SetAnchor(0.5, 0.5)
+	checkVertices(t, "center anchor", s, [4][2]float32{{-40, -5}, {60, -5}, {60, 45}, {-40, 45}})
+
+	s.SetScale(2, 2)
+	s.UpdateTransform()
+	checkVertices(t, "scaled", s, [4][2]float32{{-90, -30}, {110, -30}, {110, 70}, {-90, 70}})
+	if s.Width() != 200 || s.Height() != 100 {
+		t.Errorf("size = %vx%v, want 200x100", s.Width(), s.Height())
+	}
+}
+
+func TestSpriteTrim(t *testing.T) {
+	//100x50 的原图裁掉透明边后剩下 (10, 5)-(70, 35)
+	frame := &Frame{
+		Rect:         image.Rect(0, 0, 60, 30),
+		Trim:         image.Rect(10, 5, 70, 35),
+		SourceWidth:  100,
+		SourceHeight: 50,
+	}
+	s := NewSprite(frame)
+	s.UpdateTransform()
+	checkVertices(t, "trim", s, [4][2]float32{{10, 5}, {70, 5}, {70, 35}, {10, 35}})
+
+	//锚点按原图大小计算
+	s.SetAnchor(1, 1)
+	checkVertices(t, "trim anchor", s, [4][2]float32{{-90, -45}, {-30, -45}, {-30, -15}, {-90, -15}})
+	if s.Width() != 100 || s.Height() != 50 {
+		t.Errorf("size = %vx%v, want 100x50", s.Width(), s.Height())
+	}
+}
+
+func TestFrameUVs(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame Frame
+		want  [4][2]float32
+	}{
+		{"plain", Frame{Rect: image.Rect(10, 20, 70, 50)},
+			[4][2]float32{{0.1, 0.2}, {0.7, 0.2}, {0.7, 0.5}, {0.1, 0.5}}},
+		//60x30 的原图顺时针旋转后在纹理中占 30x60
+		{"rotated", Frame{Rect: image.Rect(10, 20, 40, 80), Rotated: true},
+			[4][2]float32{{0.4, 0.2}, {0.4, 0.8}, {0.1, 0.8}, {0.1, 0.2}}},
+	}
+	for _, test := range tests {
+		got := test.frame.uvs(100, 100)
+		for i := range got {
+			if !near(got[i][0], test.want[i][0]) || !near(got[i][1], test.want[i][1]) {
+				t.Errorf("%s: uv %d = %v, want %v", test.name, i, got[i], test.want[i])
+			}
+		}
+	}
+}
+
+func TestSpriteColor(t *testing.T) {
+	s := NewSprite(NewFrame(nil, image.Rect(0, 0, 10, 10)))
+	s.SetTint(0xFF6600)
+	s.SetAlpha(0.5)
+	s.UpdateTransform()
+	v := s.Vertices()[0]
+	if !near(v.R, 0.5) || !near(v.G, 0.2) || !near(v.B, 0) || !near(v.A, 0.5) {
+		t.Errorf("color = (%v, %v, %v, %v), want premultiplied (0.5, 0.2, 0, 0.5)", v.R, v.G, v.B, v.A)
+	}
+}
+
+func TestSpriteNilTexture(t *testing.T) {
+	s := NewSpriteFromTexture(nil)
+	s.UpdateTransform()
+	if s.Texture() != nil {
+		t.Error("texture not nil")
+	}
+	for i, v := range s.Vertices() {
+		if v.U != 0 || v.V != 0 {
+			t.Errorf("vertex %d uv = (%v, %v), want 0", i, v.U, v.V)
+		}
+	}
+	if uvs := NewFrame(nil, image.Rect(0, 0, 10, 10)).UVs(); uvs != [4][2]float32{} {
+		t.Errorf("UVs = %v, want zeros", uvs)
+	}
+}
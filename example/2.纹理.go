@@ -41,7 +41,7 @@ func main() {
 	}
 
 	//混合函数 绘制透明纹理
-	gl.SetBlend(gl.BlendSrcAlpha, gl.BlendOneMinusSrcAlpha)
+	gl.SetBlendMode(gl.BlendModeNormal)
 
 	s := gl.NewShader(vertShader, fragShader, gl.Attrs{
 		{"position", 3, gl.Float},
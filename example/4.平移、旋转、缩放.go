@@ -10,6 +10,7 @@ import (
 	"os"
 
 	"github.com/go-gl/glfw/v3.1/glfw"
+	"github.com/jangsky215/pixi/display"
 	gl "github.com/jangsky215/pixi/internal"
 	"github.com/jangsky215/pixi/math"
 )
@@ -43,7 +44,7 @@ func main() {
 	}
 
 	//混合函数 绘制透明纹理
-	gl.SetBlend(gl.BlendSrcAlpha, gl.BlendOneMinusSrcAlpha)
+	gl.SetBlendMode(gl.BlendModeNormal)
 
 	attrs := gl.Attrs{
 		{"position", 3, gl.Float},
@@ -64,7 +65,11 @@ func main() {
 	tex.UploadImage(img)
 	gl.SetTexture(tex, 0)
 
-	aspect := float32(width) / float32(height) // = glheight / glwidth
+	sprite := display.NewSpriteFromTexture(tex)
+	sprite.SetAnchor(0.5, 0.5)
+	sprite.SetPosition(float32(width)/2, float32(height)/4)
+	sprite.SetScale(0.5, 0.5)
+
 	angle := float32(0)
 	for !window.ShouldClose() {
 		gl.Clear(1, 1, 1, 1)
@@ -75,18 +80,13 @@ func main() {
 		gl.Draw(0, 6)
 
 		angle += 0.5
-		m := &math.Matrix{}
-		m.Identity()
-		m.Scale(0.2, 0.2)
-		m.Translate(0, 0.5)
-		m.Rotate(angle * math.Pi / 180)
-		//m.Skew(10*math.RadianFactor, 0*math.RadianFactor)
-
-		vertex := make([]Vertex, len(vertices))
-		copy(vertex, vertices)
-		for i := 0; i < len(vertex); i++ {
-			vertex[i].X, vertex[i].Y = m.Apply(vertex[i].X, vertex[i].Y)
-			vertex[i].Y *= aspect
+		sprite.SetRotation(angle * math.Pi / 180)
+		sprite.UpdateTransform()
+
+		//Sprite 输出的是像素坐标，转换成标准化设备坐标
+		vertex := make([]Vertex, 4)
+		for i, v := range sprite.Vertices() {
+			vertex[i] = Vertex{v.X/float32(width)*2 - 1, 1 - v.Y/float32(height)*2, 0, v.R, v.G, v.B, v.U, v.V}
 		}
 		vertexBuffer.Upload(vertex)
 
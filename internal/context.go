@@ -1,5 +1,7 @@
 package internal
 
+import "fmt"
+
 type DirtyFlag uint32
 
 const (
@@ -8,52 +10,234 @@ const (
 	dirtyDepth
 	dirtyTarget
 	dirtyScissor
+	dirtySampler
 	dirtyInvalid = 0
+	dirtyAll     = dirtyTexture | dirtyBlend | dirtyDepth | dirtyTarget | dirtyScissor | dirtySampler
 )
 
+// Stats 一帧内的渲染统计，StateChanges 包括混合、深度、裁剪和采样器状态的设置
+type Stats struct {
+	DrawCalls         int
+	Triangles         int
+	ShaderSwitches    int
+	TextureBinds      int
+	BufferUploadBytes int
+	TargetSwitches    int
+	StateChanges      int
+}
+
+// PushTarget 保存的状态
+type targetState struct {
+	target   *Target
+	viewport [4]int
+}
+
 type Context struct {
 	context
 	dirtyFlag          DirtyFlag
 	attrs              Attrs
 	blendSrc, blendDst BlendFormat
+	blendMode          BlendMode
+	blendAuto          bool
 	depth              DepthFormat
 	depthmask          bool
 	scissor            bool
 	shader             *Shader
-	texture            [8]*Texture
+	texture            []*Texture
+	sampler            []*Sampler
+	textureDirty       uint64 //每个槽位一位
+	samplerDirty       uint64
+	slotUse            []uint64 //BindTexture 按最近使用时间选择槽位
+	useClock           uint64
 	target             *Target
+	viewport           [4]int
+	targetStack        []targetState
+	stats              Stats
+	lastStats          Stats
+	lost               bool
+
+	caps           Caps
+	shaderCacheDir string
+	fbWidth        int //默认帧缓冲的大小，由窗口设置
+	fbHeight       int
+	placeholders   map[uint32]*Texture //按纹理类型缓存的 1x1 占位纹理
+}
+
+// InitOptions 初始化选项，MinMajor、MinMinor 不为 0 时驱动的 GL 版本低于它们 Init 返回错误
+type InitOptions struct {
+	MinMajor, MinMinor int
+	ShaderCacheDir     string
+	Debug              bool
+	Logger             Logger
+}
+
+// Caps 驱动的版本信息和能力，MaxTextureUnits 为片元着色器可用的纹理单元数
+type Caps struct {
+	Version             string
+	Major, Minor        int
+	GLSLVersion         string
+	Vendor              string
+	Renderer            string
+	MaxTextureSize      int
+	MaxTextureUnits     int
+	MaxSamples          int
+	MaxColorAttachments int
+	MaxAnisotropy       float32
+	Extensions          map[string]bool
+}
+
+func (caps Caps) HasExtension(name string) bool {
+	return caps.Extensions[name]
+}
+
+// AtLeast 判断 GL 版本是否不低于 major.minor
+func (caps Caps) AtLeast(major, minor int) bool {
+	return caps.Major > major || caps.Major == major && caps.Minor >= minor
 }
 
 func newContext() *Context {
 	return &Context{}
 }
 
+const maxSlots = 64
+
+// 纹理槽位的数量，最后一个纹理单元留给上传时绑定纹理使用；槽位的脏标记用 uint64 保存，最多 64 个
+func (c *Context) resizeSlots() {
+	slots := c.caps.MaxTextureUnits - 1
+	if slots < 1 {
+		slots = 1
+	}
+	if slots > maxSlots {
+		slots = maxSlots
+	}
+	texture := make([]*Texture, slots)
+	copy(texture, c.texture)
+	c.texture = texture
+	sampler := make([]*Sampler, slots)
+	copy(sampler, c.sampler)
+	c.sampler = sampler
+	slotUse := make([]uint64, slots)
+	copy(slotUse, c.slotUse)
+	c.slotUse = slotUse
+
+	c.textureDirty = ^uint64(0)
+	c.samplerDirty = ^uint64(0)
+	c.dirtyFlag |= dirtyTexture | dirtySampler
+}
+
 var theContext *Context
 
+// 非预乘纹理使用 BlendModeMultiply、BlendModeScreen 的警告每种模式只输出一次
+var blendWarned = make(map[BlendMode]bool)
+
 func GetContext() *Context {
 	return theContext
 }
 
+// Caps 返回 Init 时查询到的驱动能力
+func (c *Context) Caps() Caps {
+	return c.caps
+}
+
+// TextureSlots 返回可以用于 SetTexture 的槽位数量
+func (c *Context) TextureSlots() int {
+	return len(c.texture)
+}
+
 func SetAttrs(attrs Attrs) {
 	theContext.attrs = attrs
 }
 
+// SetShaderCacheDir 设置 program 二进制缓存目录，为空时不使用缓存
+func SetShaderCacheDir(dir string) {
+	theContext.shaderCacheDir = dir
+}
+
+// SetFramebufferSize 设置默认帧缓冲（窗口）的像素大小，GL 无法查询它，
+// 创建窗口和窗口大小改变时需要调用，读取默认帧缓冲时以它为边界
+func SetFramebufferSize(width, height int) {
+	theContext.fbWidth = width
+	theContext.fbHeight = height
+}
+
 func SetShader(shader *Shader) {
 	c := theContext
 	if c.shader != shader {
 		c.shader = shader
 		shader.bind()
+		c.stats.ShaderSwitches++
+		//需要重新检查 sampler 和纹理是否匹配
+		c.dirtyFlag |= dirtyTexture
 	}
 }
 
+// BeginFrame 清零当前帧的统计
+func BeginFrame() {
+	theContext.stats = Stats{}
+}
+
+// EndFrame 结束当前帧，之后 Stats 返回这一帧的统计
+func EndFrame() {
+	c := theContext
+	c.lastStats = c.stats
+	c.stats = Stats{}
+}
+
+// Stats 返回最近一次 EndFrame 时的统计
+func (c *Context) Stats() Stats {
+	return c.lastStats
+}
+
 func SetTexture(tex *Texture, slot int) {
 	c := theContext
+	if slot < 0 || slot >= len(c.texture) {
+		panic(fmt.Errorf("set texture: slot %d out of range [0, %d)", slot, len(c.texture)))
+	}
 	if c.texture[slot] != tex {
 		c.dirtyFlag |= dirtyTexture
+		c.textureDirty |= 1 << uint(slot)
 		c.texture[slot] = tex
 	}
 }
 
+// BindTexture 把纹理放到一个槽位上并返回槽位：已经在某个槽位上时直接返回，
+// 否则使用空槽位或最久没有使用的槽位；合批时一批内绑定的纹理数不要超过 TextureSlots
+func BindTexture(tex *Texture) int {
+	c := theContext
+	slot := -1
+	lru := 0
+	for i, t := range c.texture {
+		if t == tex {
+			slot = i
+			break
+		}
+		if c.slotUse[i] < c.slotUse[lru] {
+			lru = i
+		}
+	}
+	if slot < 0 {
+		slot = lru
+		SetTexture(tex, slot)
+	}
+	//只有 BindTexture 更新使用顺序，RestoreState 等直接调用 SetTexture 不会打乱
+	c.useClock++
+	c.slotUse[slot] = c.useClock
+	return slot
+}
+
+// SetSampler 给槽位绑定共享的采样器，覆盖纹理自身的采样方式，nil 表示使用纹理自身的设置
+func SetSampler(sampler *Sampler, slot int) {
+	c := theContext
+	if slot < 0 || slot >= len(c.sampler) {
+		panic(fmt.Errorf("set sampler: slot %d out of range [0, %d)", slot, len(c.sampler)))
+	}
+	if c.sampler[slot] != sampler {
+		c.dirtyFlag |= dirtySampler
+		c.samplerDirty |= 1 << uint(slot)
+		c.sampler[slot] = sampler
+	}
+}
+
 func SetTarget(target *Target) {
 	c := theContext
 	if c.target != target {
@@ -62,13 +246,49 @@ func SetTarget(target *Target) {
 	}
 }
 
+// PushTarget 保存当前的目标和视口，切换到 target 并把视口设为 target 的大小；target 为 nil 时视口不变
+func PushTarget(target *Target) {
+	c := theContext
+	c.targetStack = append(c.targetStack, targetState{c.target, c.viewport})
+	SetTarget(target)
+	if target != nil {
+		Viewport(0, 0, target.width, target.height)
+	}
+}
+
+// PopTarget 恢复上一次 PushTarget 之前的目标和视口
+func PopTarget() {
+	c := theContext
+	if len(c.targetStack) == 0 {
+		panic("pop target: stack is empty")
+	}
+	state := c.targetStack[len(c.targetStack)-1]
+	c.targetStack[len(c.targetStack)-1] = targetState{}
+	c.targetStack = c.targetStack[:len(c.targetStack)-1]
+
+	SetTarget(state.target)
+	if state.viewport != c.viewport {
+		v := state.viewport
+		Viewport(v[0], v[1], v[2], v[3])
+	}
+}
+
 func SetBlend(src, dst BlendFormat) {
 	c := theContext
 	c.dirtyFlag |= dirtyBlend
+	c.blendAuto = false
 	c.blendSrc = src
 	c.blendDst = dst
 }
 
+// SetBlendMode 使用混合预设，绘制时按槽位 0 上纹理的 AlphaMode 选择预乘或非预乘的混合因子
+func SetBlendMode(mode BlendMode) {
+	c := theContext
+	c.dirtyFlag |= dirtyBlend
+	c.blendAuto = true
+	c.blendMode = mode
+}
+
 func SetDepth(depth DepthFormat) {
 	c := theContext
 	c.dirtyFlag |= dirtyDepth
@@ -87,7 +307,110 @@ func EnableScissor(enable bool) {
 	c.scissor = enable
 }
 
+// State 是 SaveState 保存的状态快照
+type State struct {
+	attrs              Attrs
+	blendSrc, blendDst BlendFormat
+	blendMode          BlendMode
+	blendAuto          bool
+	depth              DepthFormat
+	depthmask          bool
+	scissor            bool
+	shader             *Shader
+	texture            []*Texture
+	sampler            []*Sampler
+	target             *Target
+	viewport           [4]int
+}
+
+// Invalidate 在其它代码直接修改了 GL 状态之后调用，把所有状态标记为脏，下次绘制时全部重新设置；
+// pixi 不管理的混合方程、颜色掩码、面剔除、模板测试、裁剪框和 FRAMEBUFFER_SRGB 恢复为默认值
+func Invalidate() {
+	c := theContext
+	c.dirtyFlag = dirtyAll
+	c.textureDirty = ^uint64(0)
+	c.samplerDirty = ^uint64(0)
+	resetState(c.viewport)
+	if c.shader != nil {
+		c.shader.bufferDirty = true
+		c.shader.bind()
+	}
+	v := c.viewport
+	Viewport(v[0], v[1], v[2], v[3])
+}
+
+// SaveState 保存当前设置的状态，配合 RestoreState 使用
+func SaveState() State {
+	c := theContext
+	return State{
+		attrs:     c.attrs,
+		blendSrc:  c.blendSrc,
+		blendDst:  c.blendDst,
+		blendMode: c.blendMode,
+		blendAuto: c.blendAuto,
+		depth:     c.depth,
+		depthmask: c.depthmask,
+		scissor:   c.scissor,
+		shader:    c.shader,
+		texture:   append([]*Texture(nil), c.texture...),
+		sampler:   append([]*Sampler(nil), c.sampler...),
+		target:    c.target,
+		viewport:  c.viewport,
+	}
+}
+
+// RestoreState 恢复 SaveState 保存的状态，只有变化的部分会在下次绘制时重新设置
+func RestoreState(state State) {
+	c := theContext
+	c.attrs = state.attrs
+
+	if state.blendAuto != c.blendAuto || state.blendMode != c.blendMode ||
+		state.blendSrc != c.blendSrc || state.blendDst != c.blendDst {
+		c.dirtyFlag |= dirtyBlend
+		c.blendAuto = state.blendAuto
+		c.blendMode = state.blendMode
+		c.blendSrc = state.blendSrc
+		c.blendDst = state.blendDst
+	}
+	if state.depth != c.depth || state.depthmask != c.depthmask {
+		c.dirtyFlag |= dirtyDepth
+		c.depth = state.depth
+		c.depthmask = state.depthmask
+	}
+	if state.scissor != c.scissor {
+		EnableScissor(state.scissor)
+	}
+
+	if state.shader != nil {
+		SetShader(state.shader)
+	} else {
+		c.shader = nil
+	}
+	for i := range c.texture {
+		var tex *Texture
+		if i < len(state.texture) {
+			tex = state.texture[i]
+		}
+		SetTexture(tex, i)
+	}
+	for i := range c.sampler {
+		var sampler *Sampler
+		if i < len(state.sampler) {
+			sampler = state.sampler[i]
+		}
+		SetSampler(sampler, i)
+	}
+	SetTarget(state.target)
+	if state.viewport != c.viewport {
+		v := state.viewport
+		Viewport(v[0], v[1], v[2], v[3])
+	}
+}
+
 func (c *Context) commit() {
+	if c.shader == nil {
+		panic("draw: no shader set")
+	}
 	c.shader.applyVertex()
 
 	if c.dirtyFlag == dirtyInvalid {
@@ -95,13 +418,38 @@ func (c *Context) commit() {
 	}
 
 	if c.dirtyFlag&dirtyTexture != 0 {
-		for i := 0; i < len(c.shader.samplers); i++ {
-			c.texture[i].activeTexture(i)
+		c.applyTextures()
+	}
+
+	if c.dirtyFlag&dirtySampler != 0 {
+		for i, sampler := range c.sampler {
+			if c.samplerDirty&(1<<uint(i)) != 0 {
+				sampler.bind(i)
+			}
 		}
+		c.samplerDirty = 0
+		c.stats.StateChanges++
 	}
 
 	if c.dirtyFlag&dirtyTarget != 0 {
 		c.target.bind()
+		c.stats.TargetSwitches++
+	}
+
+	if c.blendAuto && c.dirtyFlag&(dirtyBlend|dirtyTexture) != 0 {
+		alpha := AlphaPremultiplied
+		if c.texture[0] != nil {
+			alpha = c.texture[0].alpha
+		}
+		if alpha == AlphaStraight && c.blendMode.premultipliedOnly() && !blendWarned[c.blendMode] {
+			blendWarned[c.blendMode] = true
+			warnf("blend mode %s expects premultiplied colors, the shader must premultiply straight alpha textures", c.blendMode)
+		}
+		src, dst := c.blendMode.factors(alpha)
+		if src != c.blendSrc || dst != c.blendDst {
+			c.blendSrc, c.blendDst = src, dst
+			c.dirtyFlag |= dirtyBlend
+		}
 	}
 
 	if c.dirtyFlag&dirtyBlend != 0 {
@@ -111,6 +459,7 @@ func (c *Context) commit() {
 			enable(Blend)
 			blendFunc(c.blendSrc, c.blendDst)
 		}
+		c.stats.StateChanges++
 	}
 
 	if c.dirtyFlag&dirtyDepth != 0 {
@@ -121,6 +470,7 @@ func (c *Context) commit() {
 			depthFunc(c.depth)
 		}
 		depthMask(c.depthmask)
+		c.stats.StateChanges++
 	}
 
 	if c.dirtyFlag&dirtyScissor != 0 {
@@ -129,14 +479,45 @@ func (c *Context) commit() {
 		} else {
 			disable(ScissorTest)
 		}
+		c.stats.StateChanges++
 	}
 
 	c.dirtyFlag = dirtyInvalid
+	checkError()
+}
+
+// 检查当前 shader 的每个 sampler 都绑定了类型匹配的纹理，只重新绑定变化了的槽位
+func (c *Context) applyTextures() {
+	shader := c.shader
+	if len(shader.samplerTargets) > len(c.texture) {
+		panic(fmt.Errorf("shader uses %d samplers, only %d texture slots available", len(shader.samplerTargets), len(c.texture)))
+	}
+	for i, target := range shader.samplerTargets {
+		tex := c.texture[i]
+		if tex != nil && tex.gltarget != target {
+			if debugMode {
+				warnf("texture slot %d: texture type does not match sampler %q, using a placeholder", i, shader.samplerNames[i])
+			}
+			tex = nil
+		}
+		//合批时 uSamplers[8] 这样的数组通常只用到前几个，没有纹理的槽位绑定占位纹理
+		if tex == nil {
+			tex = c.placeholder(target)
+		}
+		if c.textureDirty&(1<<uint(i)) != 0 || tex.samplerDirty {
+			tex.activeTexture(i)
+			c.textureDirty &^= 1 << uint(i)
+			c.stats.TextureBinds++
+		}
+	}
 }
 
 func Draw(start, count int) {
 	if count > 0 {
-		theContext.commit()
+		c := theContext
+		c.commit()
 		glDraw(start, count)
+		c.stats.DrawCalls++
+		c.stats.Triangles += count / 3
 	}
 }
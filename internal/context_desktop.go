@@ -7,6 +7,8 @@ import (
 	"runtime"
 
 	"image"
+	"image/color"
+	"os"
 	"reflect"
 	"strings"
 	"unsafe"
@@ -18,16 +20,74 @@ type context struct {
 }
 
 func Init() error {
+	return InitWithOptions(InitOptions{})
+}
+
+func InitWithOptions(opts InitOptions) error {
 	if err := gl.Init(); err != nil {
 		return err
 	}
 	ctx := newContext()
+	ctx.caps = queryCaps()
+	if !ctx.caps.AtLeast(opts.MinMajor, opts.MinMinor) {
+		return fmt.Errorf("OpenGL %d.%d is required, the driver provides %s", opts.MinMajor, opts.MinMinor, ctx.caps.Version)
+	}
+	ctx.shaderCacheDir = opts.ShaderCacheDir
+	if opts.Logger != nil {
+		SetLogger(opts.Logger)
+	}
+	if opts.Debug {
+		debugMode = true
+	}
+	ctx.resizeSlots()
+	ctx.queryViewport()
 
 	theContext = ctx
+	installDebugCallback()
 
 	return nil
 }
 
+func queryCaps() Caps {
+	caps := Caps{
+		Version:     gl.GoStr(gl.GetString(gl.VERSION)),
+		GLSLVersion: gl.GoStr(gl.GetString(gl.SHADING_LANGUAGE_VERSION)),
+		Vendor:      gl.GoStr(gl.GetString(gl.VENDOR)),
+		Renderer:    gl.GoStr(gl.GetString(gl.RENDERER)),
+		Extensions:  make(map[string]bool),
+	}
+	getInt := func(pname uint32) int {
+		var v int32
+		gl.GetIntegerv(pname, &v)
+		return int(v)
+	}
+	caps.Major = getInt(gl.MAJOR_VERSION)
+	caps.Minor = getInt(gl.MINOR_VERSION)
+	caps.MaxTextureSize = getInt(gl.MAX_TEXTURE_SIZE)
+	caps.MaxTextureUnits = getInt(gl.MAX_TEXTURE_IMAGE_UNITS)
+	caps.MaxSamples = getInt(gl.MAX_SAMPLES)
+	caps.MaxColorAttachments = getInt(gl.MAX_COLOR_ATTACHMENTS)
+
+	count := getInt(gl.NUM_EXTENSIONS)
+	for i := 0; i < count; i++ {
+		caps.Extensions[gl.GoStr(gl.GetStringi(gl.EXTENSIONS, uint32(i)))] = true
+	}
+
+	caps.MaxAnisotropy = 1
+	if caps.Extensions["GL_EXT_texture_filter_anisotropic"] || caps.Extensions["GL_ARB_texture_filter_anisotropic"] {
+		gl.GetFloatv(maxTextureMaxAnisotropy, &caps.MaxAnisotropy)
+	}
+	return caps
+}
+
+func (c *Context) queryViewport() {
+	var viewport [4]int32
+	gl.GetIntegerv(gl.VIEWPORT, &viewport[0])
+	for i, v := range viewport {
+		c.viewport[i] = int(v)
+	}
+}
+
 func (c *context) callNonBlock(f func()) {
 	f()
 }
@@ -40,14 +100,21 @@ type Buffer struct {
 	gltype uint32
 
 	stride int32
+	res    *resource
+	//上下文丢失后按原来的大小重新分配
+	size        int
+	drawType    uint32
+	restoreFunc func(buffer *Buffer)
 }
 
 func newBuffer(gltype uint32, slice interface{}, stride int32) *Buffer {
 	buffer := &Buffer{
 		gltype: gltype,
 		stride: stride,
+		res:    newResource(ResourceBuffer),
 	}
 	gl.GenBuffers(1, &buffer.glid)
+	setRestore(buffer.res, buffer, (*Buffer).restore)
 
 	if slice != nil {
 		buffer.update(gl.STATIC_DRAW, slice)
@@ -68,6 +135,21 @@ func NewIndexBuffer(slice []uint16) *Buffer {
 
 func (buffer *Buffer) delete() {
 	gl.DeleteBuffers(1, &buffer.glid)
+	buffer.res.release()
+}
+
+// SetLabel 设置在 Resources 中显示的名字
+func (buffer *Buffer) SetLabel(label string) {
+	buffer.res.setLabel(label)
+	//Gen 得到的名字第一次绑定后才是真正的对象，先绑定才能设置名字
+	gl.BindVertexArray(0)
+	buffer.bind()
+	objectLabel(gl.BUFFER, buffer.glid, label)
+	checkError()
+}
+
+func (buffer *Buffer) Label() string {
+	return buffer.res.getLabel()
 }
 
 func (buffer *Buffer) bind() {
@@ -80,9 +162,14 @@ func (buffer *Buffer) update(drawType uint32, slice interface{}) {
 		panic(errors.New("expected slice"))
 	}
 	size := val.Len() * int(val.Type().Elem().Size())
+	buffer.size = size
+	buffer.drawType = drawType
 	gl.BindVertexArray(0)
 	buffer.bind()
 	gl.BufferData(buffer.gltype, size, gl.Ptr(slice), drawType)
+	theContext.stats.BufferUploadBytes += size
+	buffer.res.setBytes(int64(size))
+	checkError()
 }
 
 func (buffer *Buffer) Upload(slice interface{}) {
@@ -93,13 +180,55 @@ func (buffer *Buffer) Upload(slice interface{}) {
  *	Texture
  */
 type Texture struct {
-	glid   uint32
-	mipmap bool
+	glid          uint32
+	gltarget      uint32
+	mipmap        bool
+	mipmapManual  bool //mip 层由 UploadLevel 上传，不再自动生成
+	levels        int
+	layers        int //数组的层数，立方体纹理为 6
+	format        TextureFormat
+	formatSet     bool //格式由调用者指定，UploadImage 不再按图片类型改变格式
+	alpha         AlphaMode
+	swizzle       [4]int32
+	width, height int
+	scratch       []uint8 //YCbCr 转换缓冲区
+
+	//LoadTexture 载入的压缩纹理：GL internalformat 和压缩块的宽、高、字节数
+	compressed                          uint32
+	blockWidth, blockHeight, blockBytes int
+	//LoadTexture 的数据，上下文恢复后重新上传；之后用其他方式更新了内容时清空
+	container *textureContainer
+
+	sampler      SamplerOptions
+	samplerDirty bool
+	res          *resource
+	restoreFunc  func(tex *Texture)
 }
 
+// NewTexture 创建 RGBA8 纹理，UploadImage 时按图片类型选择格式
 func NewTexture() *Texture {
-	tex := &Texture{}
+	return newTexture(gl.TEXTURE_2D, FormatRGBA8)
+}
+
+// NewTextureWithFormat 创建指定格式的纹理，UploadImage 的图片需要与格式兼容，
+// 8 位 RGBA 图片可以上传到 FormatSRGB8Alpha8 纹理
+func NewTextureWithFormat(format TextureFormat) *Texture {
+	tex := newTexture(gl.TEXTURE_2D, format)
+	tex.formatSet = true
+	return tex
+}
+
+func newTexture(gltarget uint32, format TextureFormat) *Texture {
+	tex := &Texture{
+		gltarget:     gltarget,
+		layers:       1,
+		format:       format,
+		sampler:      DefaultSampler,
+		samplerDirty: true,
+		res:          newResource(ResourceTexture),
+	}
 	gl.GenTextures(1, &tex.glid)
+	setRestore(tex.res, tex, (*Texture).restore)
 
 	runtime.SetFinalizer(tex, (*Texture).delete)
 
@@ -108,66 +237,637 @@ func NewTexture() *Texture {
 
 func (tex *Texture) delete() {
 	gl.DeleteTextures(1, &tex.glid)
+	tex.res.release()
+}
+
+// SetLabel 设置在 Resources 中显示的名字
+func (tex *Texture) SetLabel(label string) {
+	tex.res.setLabel(label)
+	tex.bind()
+	objectLabel(gl.TEXTURE, tex.glid, label)
+	checkError()
+}
+
+func (tex *Texture) Label() string {
+	return tex.res.getLabel()
+}
+
+// 按当前的大小、格式和层级估算显存
+func (tex *Texture) updateBytes() {
+	if tex.compressed != 0 {
+		//uploadContainer 已经按压缩数据的大小设置
+		return
+	}
+	tex.res.setBytes(textureBytes(tex.format, tex.width, tex.height, tex.levels, tex.layers))
 }
 
 func (tex *Texture) bind() {
-	gl.ActiveTexture(gl.TEXTURE7)
-	gl.BindTexture(gl.TEXTURE_2D, tex.glid)
+	//最后一个纹理单元不属于任何槽位
+	gl.ActiveTexture(gl.TEXTURE0 + uint32(len(theContext.texture)))
+	gl.BindTexture(tex.gltarget, tex.glid)
 }
 
 func (tex *Texture) activeTexture(i int) {
 	gl.ActiveTexture(gl.TEXTURE0 + uint32(i))
-	gl.BindTexture(gl.TEXTURE_2D, tex.glid)
+	gl.BindTexture(tex.gltarget, tex.glid)
+	if tex.samplerDirty {
+		tex.samplerDirty = false
+		applySampler(tex.sampler, tex.mipmap,
+			func(pname uint32, param int32) { gl.TexParameteri(tex.gltarget, pname, param) },
+			func(pname uint32, params *float32) { gl.TexParameterfv(tex.gltarget, pname, params) })
+	}
+}
+
+// SetSampler 设置纹理自身的采样方式，下次绘制绑定时生效；槽位上绑定了 Sampler 时以 Sampler 为准
+func (tex *Texture) SetSampler(opts SamplerOptions) {
+	tex.sampler = opts.withDefaults()
+	tex.samplerDirty = true
+	theContext.dirtyFlag |= dirtyTexture
 }
 
+func (tex *Texture) Sampler() SamplerOptions {
+	return tex.sampler
+}
+
+// EnableMipmap 开启 mipmap，之后每次 Upload、SubUpload 都会重新生成
 func (tex *Texture) EnableMipmap() {
 	if tex.mipmap {
 		return
 	}
-	tex.bind()
+	tex.enableMipmapFilter()
+	//还没有上传数据时在 Upload 之后生成
+	if tex.width > 0 && tex.height > 0 {
+		tex.generateMipmap()
+	}
+}
+
+func (tex *Texture) enableMipmapFilter() {
 	tex.mipmap = true
-	gl.GenerateMipmap(gl.TEXTURE_2D)
+	if tex.sampler.MinFilter == FilterLinear {
+		tex.sampler.MinFilter = FilterLinearMipmapLinear
+	}
+	tex.samplerDirty = true
+	theContext.dirtyFlag |= dirtyTexture
+}
+
+func (tex *Texture) generateMipmap() {
+	if !tex.mipmap || tex.mipmapManual {
+		return
+	}
+	tex.bind()
+	gl.TexParameteri(tex.gltarget, gl.TEXTURE_MAX_LEVEL, 1000)
+	gl.GenerateMipmap(tex.gltarget)
+	tex.levels = mipLevels(tex.width, tex.height)
+	tex.updateBytes()
+	checkError()
 }
 
+// UploadLevel 上传指定的 mip 层，之后不再自动生成 mipmap
+func (tex *Texture) UploadLevel(level int, pixels []uint8, width, height int) {
+	tex.check2D()
+	tex.checkUncompressed()
+	tex.container = nil
+	if level == 0 {
+		tex.width = width
+		tex.height = height
+	}
+	var ptr unsafe.Pointer
+	if pixels != nil {
+		checkPixels(len(pixels), width, height, tex.format.BytesPerPixel())
+		//空切片不能取地址
+		if len(pixels) > 0 {
+			ptr = gl.Ptr(pixels)
+		}
+	}
+	internalFormat, format, xtype := textureFormat(tex.format)
+
+	tex.bind()
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexImage2D(gl.TEXTURE_2D, int32(level), internalFormat, int32(width), int32(height), 0, format, xtype, ptr)
+
+	tex.mipmapManual = true
+	if level+1 > tex.levels {
+		tex.levels = level + 1
+	}
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, int32(tex.levels-1))
+	if tex.levels > 1 && !tex.mipmap {
+		tex.enableMipmapFilter()
+	}
+	tex.updateBytes()
+	checkError()
+}
+
+// UploadMipChain 上传 GenerateMipChain 生成的 mip 链，纹理需要是 RGBA8 或 SRGB8Alpha8 格式；
+// gamma 为 true 生成的链应上传到 NewTextureWithFormat(FormatSRGB8Alpha8) 创建的纹理；链为空或有空的层时返回错误
+func (tex *Texture) UploadMipChain(levels []*image.RGBA) error {
+	if len(levels) == 0 {
+		return errors.New("upload mip chain: no levels")
+	}
+	for i, img := range levels {
+		if img.Rect.Empty() {
+			return fmt.Errorf("upload mip chain: level %d is empty", i)
+		}
+	}
+	if !tex.formatSet && tex.format != FormatRGBA8 {
+		tex.format = FormatRGBA8
+		tex.setSwizzle(gl.RED, gl.GREEN, gl.BLUE, gl.ALPHA)
+	}
+	tex.setAlpha(AlphaPremultiplied)
+	for i, img := range levels {
+		rgba := imageToRGBA(img, tex.format)
+		tex.UploadLevel(i, rgba.Pix, rgba.Rect.Dx(), rgba.Rect.Dy())
+	}
+	return nil
+}
+
+func (tex *Texture) Format() TextureFormat {
+	return tex.format
+}
+
+func (tex *Texture) Width() int {
+	return tex.width
+}
+
+func (tex *Texture) Height() int {
+	return tex.height
+}
+
+// SetAlphaMode 设置 UploadImage 上传时使用的 alpha 模式，需要时会转换图片
+func (tex *Texture) SetAlphaMode(mode AlphaMode) {
+	tex.setAlpha(mode)
+}
+
+// 自动混合因子由槽位 0 上纹理的 AlphaMode 决定，改变后需要重新计算
+func (tex *Texture) setAlpha(mode AlphaMode) {
+	if tex.alpha != mode {
+		tex.alpha = mode
+		theContext.dirtyFlag |= dirtyBlend
+	}
+}
+
+// AlphaMode 返回纹理数据的 alpha 模式
+func (tex *Texture) AlphaMode() AlphaMode {
+	return tex.alpha
+}
+
+// UploadImage 按图片类型选择纹理格式，并按 AlphaMode 转换成预乘或非预乘的数据；
+// 与 AlphaMode 一致的 image.RGBA、image.NRGBA 以及 Gray、Alpha 直接按 Stride 上传，不复制像素
 func (tex *Texture) UploadImage(img image.Image) {
-	var rgba *image.RGBA
-	if t, ok := img.(*image.RGBA); ok {
-		rgba = t
+	tex.uploadImage(img, false, 0, 0)
+}
+
+// SubUploadImage 把图片更新到纹理的 (x, y) 处，图片格式需要与纹理格式一致
+func (tex *Texture) SubUploadImage(img image.Image, x, y int) {
+	tex.uploadImage(img, true, x, y)
+}
+
+func (tex *Texture) uploadImage(img image.Image, sub bool, x, y int) {
+	if _, ok := img.(*image.YCbCr); !ok {
+		tex.scratch = nil
+	}
+	straight := tex.alpha == AlphaStraight
+	switch t := img.(type) {
+	case *image.RGBA:
+		if !straight {
+			tex.uploadPix(t.Pix, t.Rect, t.Stride, 4, FormatRGBA8, gl.RGBA, gl.UNSIGNED_BYTE, sub, x, y)
+			return
+		}
+	case *image.NRGBA:
+		if straight {
+			tex.uploadPix(t.Pix, t.Rect, t.Stride, 4, FormatRGBA8, gl.RGBA, gl.UNSIGNED_BYTE, sub, x, y)
+			return
+		}
+	case *image.Gray:
+		//uploadPix 先检查格式，通过后再设置 swizzle
+		tex.uploadPix(t.Pix, t.Rect, t.Stride, 1, FormatR8, gl.RED, gl.UNSIGNED_BYTE, sub, x, y)
+		if !sub {
+			tex.setSwizzle(gl.RED, gl.RED, gl.RED, gl.ONE)
+		}
+		return
+	case *image.Alpha:
+		tex.uploadPix(t.Pix, t.Rect, t.Stride, 1, FormatR8, gl.RED, gl.UNSIGNED_BYTE, sub, x, y)
+		if !sub {
+			if straight {
+				tex.setSwizzle(gl.ONE, gl.ONE, gl.ONE, gl.RED)
+			} else {
+				tex.setSwizzle(gl.RED, gl.RED, gl.RED, gl.RED)
+			}
+		}
+		return
+	case *image.RGBA64:
+		if !straight {
+			tex.uploadPix16(t.Pix, t.Rect, t.Stride, sub, x, y)
+			return
+		}
+	case *image.NRGBA64:
+		if straight {
+			tex.uploadPix16(t.Pix, t.Rect, t.Stride, sub, x, y)
+			return
+		}
+	case *image.YCbCr:
+		tex.uploadYCbCr(t, sub, x, y)
+		return
+	}
+
+	bounds := image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())
+	if straight {
+		nrgba := image.NewNRGBA(bounds)
+		draw.Draw(nrgba, bounds, img, img.Bounds().Min, draw.Src)
+		tex.uploadImage(nrgba, sub, x, y)
 	} else {
-		rgba = image.NewRGBA(img.Bounds())
-		if rgba.Stride != rgba.Rect.Size().X*4 {
-			panic(errors.New("unsupported stride"))
+		rgba := image.NewRGBA(bounds)
+		draw.Draw(rgba, bounds, img, img.Bounds().Min, draw.Src)
+		tex.uploadImage(rgba, sub, x, y)
+	}
+}
+
+// UploadNRGBA 不做转换直接上传非预乘的数据
+func (tex *Texture) UploadNRGBA(img *image.NRGBA) {
+	tex.setAlpha(AlphaStraight)
+	tex.UploadImage(img)
+}
+
+// UploadGray 上传为单通道 R8 纹理，采样结果为 (r, r, r, 1)
+func (tex *Texture) UploadGray(img *image.Gray) {
+	tex.UploadImage(img)
+}
+
+// UploadRGBA64 上传为 RGBA16F 纹理
+func (tex *Texture) UploadRGBA64(img *image.RGBA64) {
+	tex.setAlpha(AlphaPremultiplied)
+	tex.UploadImage(img)
+}
+
+func (tex *Texture) UploadNRGBA64(img *image.NRGBA64) {
+	tex.setAlpha(AlphaStraight)
+	tex.UploadImage(img)
+}
+
+// 通过 UNPACK_ROW_LENGTH 按图片的 Stride 上传，子图直接使用原来的像素
+func (tex *Texture) uploadPix(pix []uint8, rect image.Rectangle, stride, bpp int, format TextureFormat,
+	glformat, xtype uint32, sub bool, x, y int) {
+	if rect.Empty() {
+		return
+	}
+	compatible := format == tex.format || format == FormatRGBA8 && tex.format == FormatSRGB8Alpha8
+	if sub && !compatible {
+		panic(errors.New("sub upload: image format does not match texture format"))
+	}
+	if !sub && !compatible {
+		if tex.formatSet {
+			panic(errors.New("upload image: image format does not match texture format"))
+		}
+		tex.format = format
+		if format != FormatR8 {
+			tex.setSwizzle(gl.RED, gl.GREEN, gl.BLUE, gl.ALPHA)
 		}
-		draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
 	}
-	tex.Upload(rgba.Pix, rgba.Rect.Size().X, rgba.Rect.Size().Y)
+
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, int32(stride/bpp))
+	if sub {
+		tex.subUpload(gl.Ptr(pix), x, y, rect.Dx(), rect.Dy(), glformat, xtype)
+	} else {
+		tex.upload(gl.Ptr(pix), rect.Dx(), rect.Dy(), glformat, xtype)
+	}
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, 0)
+}
+
+// 16 位图片的像素是大端序，需要交换字节
+func (tex *Texture) uploadPix16(pix []uint8, rect image.Rectangle, stride int, sub bool, x, y int) {
+	gl.PixelStorei(gl.UNPACK_SWAP_BYTES, 1)
+	tex.uploadPix(pix, rect, stride, 8, FormatRGBA16F, gl.RGBA, gl.UNSIGNED_SHORT, sub, x, y)
+	gl.PixelStorei(gl.UNPACK_SWAP_BYTES, 0)
+}
+
+// YCbCr（视频帧、JPEG）在 CPU 上按行转换成 RGBA，是没有转换着色器时的后备方案；
+// 转换缓冲区保存在 scratch 中供下一帧复用，上传其他类型的图片时释放
+func (tex *Texture) uploadYCbCr(img *image.YCbCr, sub bool, x, y int) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	size := width * height * 4
+	if cap(tex.scratch) < size {
+		tex.scratch = make([]uint8, size)
+	}
+	pix := tex.scratch[:size]
+
+	//色度在水平方向的采样间隔
+	cdiv := 1
+	switch img.SubsampleRatio {
+	case image.YCbCrSubsampleRatio422, image.YCbCrSubsampleRatio420:
+		cdiv = 2
+	case image.YCbCrSubsampleRatio411, image.YCbCrSubsampleRatio410:
+		cdiv = 4
+	}
+
+	minX := img.Rect.Min.X
+	for py := img.Rect.Min.Y; py < img.Rect.Max.Y; py++ {
+		yRow := img.Y[img.YOffset(minX, py):]
+		ci := img.COffset(minX, py)
+		cbRow, crRow := img.Cb[ci:], img.Cr[ci:]
+		row := pix[(py-img.Rect.Min.Y)*width*4:]
+		for i := 0; i < width; i++ {
+			c := (minX+i)/cdiv - minX/cdiv
+			row[i*4], row[i*4+1], row[i*4+2] = color.YCbCrToRGB(yRow[i], cbRow[c], crRow[c])
+			row[i*4+3] = 255
+		}
+	}
+	tex.uploadPix(pix, image.Rect(0, 0, width, height), width*4, 4, FormatRGBA8, gl.RGBA, gl.UNSIGNED_BYTE, sub, x, y)
+}
+
+func (tex *Texture) setSwizzle(r, g, b, a int32) {
+	swizzle := [4]int32{r, g, b, a}
+	if tex.swizzle == swizzle {
+		return
+	}
+	tex.swizzle = swizzle
+	tex.bind()
+	gl.TexParameteriv(tex.gltarget, gl.TEXTURE_SWIZZLE_RGBA, &swizzle[0])
+}
+
+// 数据不够时 GL 会读越界
+func checkPixels(have, width, height, size int) {
+	if have < width*height*size {
+		panic(errors.New("not enough pixels"))
+	}
+}
+
+// UploadFloat32 上传浮点数据，每个像素 Format().Channels() 个分量；与 UploadImage 一样，
+// 没有用 NewTextureWithFormat 指定格式时切换到 RGBA32F，指定了非浮点格式时 panic
+func (tex *Texture) UploadFloat32(pixels []float32, width, height int) {
+	if !tex.format.IsFloat() {
+		if tex.formatSet {
+			panic(errors.New("upload float32: texture format is not a float format"))
+		}
+		tex.format = FormatRGBA32F
+		tex.setSwizzle(gl.RED, gl.GREEN, gl.BLUE, gl.ALPHA)
+	}
+	checkPixels(len(pixels), width, height, tex.format.Channels())
+	_, format, _ := textureFormat(tex.format)
+	tex.upload(gl.Ptr(pixels), width, height, format, gl.FLOAT)
+}
+
+func (tex *Texture) SubUploadFloat32(pixels []float32, x, y, width, height int) {
+	if !tex.format.IsFloat() {
+		panic(errors.New("sub upload float32: texture format is not a float format"))
+	}
+	checkPixels(len(pixels), width, height, tex.format.Channels())
+	_, format, _ := textureFormat(tex.format)
+	tex.subUpload(gl.Ptr(pixels), x, y, width, height, format, gl.FLOAT)
 }
 
+// Upload 按纹理格式上传原始字节，pixels 为 nil 时只分配显存
 func (tex *Texture) Upload(pixels []uint8, width, height int) {
+	//UploadFloat32 切换的格式不适用于字节数据
+	if tex.format.IsFloat() && !tex.formatSet {
+		tex.format = FormatRGBA8
+	}
 	var ptr unsafe.Pointer
 	if pixels != nil {
+		checkPixels(len(pixels), width, height, tex.format.BytesPerPixel())
 		ptr = gl.Ptr(pixels)
 	}
+	_, format, xtype := textureFormat(tex.format)
+	tex.upload(ptr, width, height, format, xtype)
+}
 
-	tex.bind()
-	if tex.mipmap {
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
-	} else {
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	}
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+func (tex *Texture) SubUpload(pixels []uint8, x, y, width, height int) {
+	checkPixels(len(pixels), width, height, tex.format.BytesPerPixel())
+	_, format, xtype := textureFormat(tex.format)
+	tex.subUpload(gl.Ptr(pixels), x, y, width, height, format, xtype)
+}
 
+func (tex *Texture) upload(ptr unsafe.Pointer, width, height int, format, xtype uint32) {
+	tex.check2D()
+	internalFormat, _, _ := textureFormat(tex.format)
+	tex.width = width
+	tex.height = height
+	//UploadImage 改变了格式时会重新指定压缩纹理，之后不再是压缩纹理
+	tex.compressed = 0
+	tex.container = nil
+
+	tex.bind()
 	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
-	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(width), int32(height), 0, gl.RGBA, gl.UNSIGNED_BYTE, ptr)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, internalFormat, int32(width), int32(height), 0, format, xtype, ptr)
+
+	//重新指定第 0 层后，之前手动上传的 mip 层大小已经不匹配
+	tex.mipmapManual = false
+	tex.levels = 1
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, 0)
+	tex.updateBytes()
+	tex.generateMipmap()
+	checkError()
 }
 
-func (tex *Texture) SubUpload(pixels []uint8, x, y, width, height int) {
+func (tex *Texture) subUpload(ptr unsafe.Pointer, x, y, width, height int, format, xtype uint32) {
+	tex.check2D()
+	tex.checkUncompressed()
+	tex.container = nil
 	tex.bind()
 	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
-	gl.TexSubImage2D(gl.TEXTURE_2D, 0, int32(x), int32(y), int32(width), int32(height),
-		gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels))
+	gl.TexSubImage2D(gl.TEXTURE_2D, 0, int32(x), int32(y), int32(width), int32(height), format, xtype, ptr)
+	tex.generateMipmap()
+	checkError()
+}
+
+// CopyFrom 把 target 第 0 个颜色附件的 rect 区域复制到纹理的左下角，target 为 nil 时从默认帧缓冲复制；
+// rect 使用 GL 坐标，纹理大小与 rect 不同时会按 rect 的大小重新分配
+func (tex *Texture) CopyFrom(target *Target, rect image.Rectangle) {
+	tex.check2D()
+	if rect.Empty() {
+		return
+	}
+	if target != nil && target.options.Samples > 1 {
+		panic(errors.New("copy texture: multisample target, resolve it first"))
+	}
+
+	if target != nil {
+		gl.BindFramebuffer(gl.READ_FRAMEBUFFER, target.glid)
+		gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+	} else {
+		gl.BindFramebuffer(gl.READ_FRAMEBUFFER, 0)
+	}
+	theContext.dirtyFlag |= dirtyTarget
+
+	tex.bind()
+	if tex.width != rect.Dx() || tex.height != rect.Dy() {
+		internalFormat, _, _ := textureFormat(tex.format)
+		tex.width, tex.height = rect.Dx(), rect.Dy()
+		gl.CopyTexImage2D(gl.TEXTURE_2D, 0, uint32(internalFormat),
+			int32(rect.Min.X), int32(rect.Min.Y), int32(rect.Dx()), int32(rect.Dy()), 0)
+		tex.mipmapManual = false
+		tex.levels = 1
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, 0)
+		tex.updateBytes()
+	} else {
+		gl.CopyTexSubImage2D(gl.TEXTURE_2D, 0, 0, 0, int32(rect.Min.X), int32(rect.Min.Y), int32(rect.Dx()), int32(rect.Dy()))
+	}
+	tex.generateMipmap()
+	checkError()
+}
+
+func (tex *Texture) check2D() {
+	if tex.gltarget != gl.TEXTURE_2D {
+		panic(errors.New("upload: not a 2D texture, use UploadLayer or UploadFace"))
+	}
+}
+
+// 压缩纹理只能通过 LoadTexture 整体载入
+func (tex *Texture) checkUncompressed() {
+	if tex.compressed != 0 {
+		panic(errors.New("upload: compressed texture, load it again with LoadTexture"))
+	}
+}
+
+// 返回 internalformat、format、type
+func textureFormat(f TextureFormat) (int32, uint32, uint32) {
+	switch f {
+	case FormatRGBA8:
+		return gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE
+	case FormatR8:
+		return gl.R8, gl.RED, gl.UNSIGNED_BYTE
+	case FormatRG8:
+		return gl.RG8, gl.RG, gl.UNSIGNED_BYTE
+	case FormatRGB8:
+		return gl.RGB8, gl.RGB, gl.UNSIGNED_BYTE
+	case FormatSRGB8Alpha8:
+		return gl.SRGB8_ALPHA8, gl.RGBA, gl.UNSIGNED_BYTE
+	case FormatRGBA16F:
+		return gl.RGBA16F, gl.RGBA, gl.HALF_FLOAT
+	case FormatRGBA32F:
+		return gl.RGBA32F, gl.RGBA, gl.FLOAT
+	case FormatR32F:
+		return gl.R32F, gl.RED, gl.FLOAT
+	case FormatDepth16:
+		return gl.DEPTH_COMPONENT16, gl.DEPTH_COMPONENT, gl.UNSIGNED_SHORT
+	case FormatDepth24:
+		return gl.DEPTH_COMPONENT24, gl.DEPTH_COMPONENT, gl.UNSIGNED_INT
+	case FormatDepth32F:
+		return gl.DEPTH_COMPONENT32F, gl.DEPTH_COMPONENT, gl.FLOAT
+	case FormatDepth24Stencil8:
+		return gl.DEPTH24_STENCIL8, gl.DEPTH_STENCIL, gl.UNSIGNED_INT_24_8
+	case FormatCompressed:
+		panic("texture format: compressed texture, load it with LoadTexture")
+	default:
+		panic("texture format: invalid format")
+	}
+}
+
+/*
+ *	Sampler
+ */
+type Sampler struct {
+	glid    uint32
+	options SamplerOptions
+	res     *resource
+}
+
+// NewSampler 创建可以在多个纹理槽位之间共享的采样器对象
+func NewSampler(opts SamplerOptions) *Sampler {
+	sampler := &Sampler{res: newResource(ResourceSampler)}
+	gl.GenSamplers(1, &sampler.glid)
+	setRestore(sampler.res, sampler, (*Sampler).restore)
+	sampler.Update(opts)
+
+	runtime.SetFinalizer(sampler, (*Sampler).delete)
+
+	return sampler
+}
+
+func (sampler *Sampler) delete() {
+	gl.DeleteSamplers(1, &sampler.glid)
+	sampler.res.release()
+}
+
+func (sampler *Sampler) Update(opts SamplerOptions) {
+	opts = opts.withDefaults()
+	sampler.options = opts
+	applySampler(opts, true,
+		func(pname uint32, param int32) { gl.SamplerParameteri(sampler.glid, pname, param) },
+		func(pname uint32, params *float32) { gl.SamplerParameterfv(sampler.glid, pname, params) })
+	checkError()
+}
+
+func (sampler *Sampler) Options() SamplerOptions {
+	return sampler.options
+}
+
+func (sampler *Sampler) bind(slot int) {
+	if sampler != nil {
+		gl.BindSampler(uint32(slot), sampler.glid)
+	} else {
+		gl.BindSampler(uint32(slot), 0)
+	}
+}
+
+// GL_EXT_texture_filter_anisotropic
+const (
+	textureMaxAnisotropy    = 0x84FE
+	maxTextureMaxAnisotropy = 0x84FF
+)
+
+func applySampler(opts SamplerOptions, mipmap bool, parami func(uint32, int32), paramfv func(uint32, *float32)) {
+	opts = opts.withDefaults()
+	minFilter := opts.MinFilter
+	if !mipmap {
+		minFilter = minFilter.withoutMipmap()
+	}
+	parami(gl.TEXTURE_MIN_FILTER, int32(minFilter))
+	parami(gl.TEXTURE_MAG_FILTER, int32(opts.MagFilter))
+	parami(gl.TEXTURE_WRAP_S, int32(opts.WrapS))
+	parami(gl.TEXTURE_WRAP_T, int32(opts.WrapT))
+	//立方体纹理的第三个方向与 T 方向一致
+	parami(gl.TEXTURE_WRAP_R, int32(opts.WrapT))
+	if opts.WrapS == WrapBorder || opts.WrapT == WrapBorder {
+		paramfv(gl.TEXTURE_BORDER_COLOR, &opts.BorderColor[0])
+	}
+
+	if max := maxAnisotropy(); max > 1 {
+		anisotropy := opts.MaxAnisotropy
+		if anisotropy < 1 {
+			anisotropy = 1
+		} else if anisotropy > max {
+			anisotropy = max
+		}
+		paramfv(textureMaxAnisotropy, &anisotropy)
+	}
+}
+
+// 1x1 透明的占位纹理，绑定到着色器声明了但没有设置纹理的槽位上
+func (c *Context) placeholder(target uint32) *Texture {
+	if tex := c.placeholders[target]; tex != nil {
+		return tex
+	}
+	if c.placeholders == nil {
+		c.placeholders = make(map[uint32]*Texture)
+	}
+
+	pix := make([]uint8, 4)
+	var tex *Texture
+	switch target {
+	case gl.TEXTURE_2D_ARRAY:
+		arr := NewTextureArray(FormatRGBA8, 1, 1, 1)
+		arr.UploadLayer(0, pix)
+		tex = arr.Texture
+	case gl.TEXTURE_CUBE_MAP:
+		cube := NewCubeTexture(FormatRGBA8, 1)
+		for face := CubePositiveX; face <= CubeNegativeZ; face++ {
+			cube.UploadFace(face, pix)
+		}
+		tex = cube.Texture
+	default:
+		tex = NewTexture()
+		tex.Upload(pix, 1, 1)
+	}
+	tex.SetLabel("pixi placeholder")
+	c.placeholders[target] = tex
+	return tex
+}
+
+func maxAnisotropy() float32 {
+	return theContext.caps.MaxAnisotropy
+}
+
+func hasExtension(name string) bool {
+	return theContext.caps.Extensions[name]
 }
 
 /*
@@ -175,27 +875,59 @@ func (tex *Texture) SubUpload(pixels []uint8, x, y, width, height int) {
  */
 type Target struct {
 	glid          uint32
-	stencil       uint32
 	width, height int
-	tex           *Texture
+	options       TargetOptions
+	colors        []*Texture
+	colorBuffers  []uint32
+	depthTex      *Texture
+	depthBuffer   uint32
+	res           *resource
 }
 
 func NewTarget(width, height int) *Target {
+	return NewTargetWithOptions(width, height, TargetOptions{})
+}
+
+// NewTargetWithOptions 按 opts 创建颜色附件和深度（模板）附件
+func NewTargetWithOptions(width, height int, opts TargetOptions) *Target {
 	target := &Target{
-		width:  width,
-		height: height,
-		tex:    NewTexture(),
+		width:   width,
+		height:  height,
+		options: opts,
+		res:     newResource(ResourceTarget),
+	}
+	target.options.Colors = append([]TextureFormat(nil), opts.colorFormats()...)
+	if max := theContext.caps.MaxColorAttachments; len(target.options.Colors) > max {
+		panic(fmt.Errorf("new target: %d color attachments exceeds GL_MAX_COLOR_ATTACHMENTS %d", len(target.options.Colors), max))
+	}
+	for i, format := range target.options.Colors {
+		if format.IsDepth() {
+			panic(fmt.Errorf("new target: color attachment %d has depth format", i))
+		}
 	}
+	if opts.Samples > 1 {
+		if opts.Depth == DepthTexture {
+			panic(errors.New("new target: multisample target cannot have a depth texture"))
+		}
+		if maxSamples := theContext.caps.MaxSamples; opts.Samples > maxSamples {
+			warnf("target samples %d exceeds GL_MAX_SAMPLES, using %d", opts.Samples, maxSamples)
+			target.options.Samples = maxSamples
+		}
+	} else {
+		target.options.Samples = 0
+	}
+
 	gl.GenFramebuffers(1, &target.glid)
+	setRestore(target.res, target, (*Target).restore)
 	target.bind()
 	theContext.dirtyFlag |= dirtyTarget
 
-	target.tex.Upload(nil, width, height)
-	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, target.tex.glid, 0)
-
-	if gl.CheckFramebufferStatus(gl.FRAMEBUFFER) != gl.FRAMEBUFFER_COMPLETE {
-		panic("init Framebuffer error")
-	}
+	target.attachColors()
+	target.setDrawBuffers()
+	target.attachDepth()
+	target.checkStatus()
+	target.updateBytes()
+	checkError()
 
 	runtime.SetFinalizer(target, (*Target).delete)
 
@@ -204,24 +936,166 @@ func NewTarget(width, height int) *Target {
 
 func (target *Target) delete() {
 	gl.DeleteFramebuffers(1, &target.glid)
-	if target.stencil > 0 {
-		gl.DeleteFramebuffers(1, &target.stencil)
+	if len(target.colorBuffers) > 0 {
+		gl.DeleteRenderbuffers(int32(len(target.colorBuffers)), &target.colorBuffers[0])
 	}
+	if target.depthBuffer > 0 {
+		gl.DeleteRenderbuffers(1, &target.depthBuffer)
+	}
+	target.res.release()
+}
+
+// SetLabel 设置在 Resources 中显示的名字
+func (target *Target) SetLabel(label string) {
+	target.res.setLabel(label)
+	objectLabel(gl.FRAMEBUFFER, target.glid, label)
+	for i, glid := range target.colorBuffers {
+		objectLabel(gl.RENDERBUFFER, glid, fmt.Sprintf("%s color%d", label, i))
+	}
+	objectLabel(gl.RENDERBUFFER, target.depthBuffer, label+" depth")
+	checkError()
+}
+
+func (target *Target) Label() string {
+	return target.res.getLabel()
+}
+
+// 只计算 renderbuffer，纹理附件由各自的 Texture 计算
+func (target *Target) updateBytes() {
+	samples := int64(1)
+	if target.options.Samples > 1 {
+		samples = int64(target.options.Samples)
+	}
+	pixels := int64(target.width) * int64(target.height) * samples
+
+	var total int64
+	for i := range target.colorBuffers {
+		total += pixels * int64(target.options.Colors[i].BytesPerPixel())
+	}
+	if target.depthBuffer > 0 {
+		total += pixels * int64(target.options.depthFormat().BytesPerPixel())
+	}
+	target.res.setBytes(total)
+}
+
+// 按 options 创建或重新分配颜色附件，多重采样时使用 renderbuffer，调用前需要绑定 framebuffer
+func (target *Target) attachColors() {
+	for i, format := range target.options.Colors {
+		attachment := gl.COLOR_ATTACHMENT0 + uint32(i)
+		if target.options.Samples > 1 {
+			if i >= len(target.colorBuffers) {
+				var glid uint32
+				gl.GenRenderbuffers(1, &glid)
+				target.colorBuffers = append(target.colorBuffers, glid)
+			}
+			target.renderbufferStorage(target.colorBuffers[i], format)
+			gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, attachment, gl.RENDERBUFFER, target.colorBuffers[i])
+		} else {
+			if i >= len(target.colors) {
+				target.colors = append(target.colors, NewTextureWithFormat(format))
+			}
+			target.colors[i].Upload(nil, target.width, target.height)
+			gl.FramebufferTexture2D(gl.FRAMEBUFFER, attachment, gl.TEXTURE_2D, target.colors[i].glid, 0)
+		}
+	}
+}
+
+func (target *Target) setDrawBuffers() {
+	drawBuffers := make([]uint32, len(target.options.Colors))
+	for i := range drawBuffers {
+		drawBuffers[i] = gl.COLOR_ATTACHMENT0 + uint32(i)
+	}
+	gl.DrawBuffers(int32(len(drawBuffers)), &drawBuffers[0])
+}
+
+func (target *Target) renderbufferStorage(glid uint32, format TextureFormat) {
+	internalFormat, _, _ := textureFormat(format)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, glid)
+	if target.options.Samples > 1 {
+		gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, int32(target.options.Samples), uint32(internalFormat),
+			int32(target.width), int32(target.height))
+	} else {
+		gl.RenderbufferStorage(gl.RENDERBUFFER, uint32(internalFormat), int32(target.width), int32(target.height))
+	}
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
 }
 
+// 按 options 创建或重新分配深度附件，调用前需要绑定 framebuffer
+func (target *Target) attachDepth() {
+	depth := target.options.Depth
+	if depth == DepthNone && target.options.Stencil {
+		depth = DepthRenderbuffer
+	}
+	format := target.options.depthFormat()
+	var attachment uint32 = gl.DEPTH_ATTACHMENT
+	if format == FormatDepth24Stencil8 {
+		attachment = gl.DEPTH_STENCIL_ATTACHMENT
+	}
+
+	switch depth {
+	case DepthRenderbuffer:
+		if target.depthBuffer == 0 {
+			gl.GenRenderbuffers(1, &target.depthBuffer)
+		}
+		target.renderbufferStorage(target.depthBuffer, format)
+		gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, attachment, gl.RENDERBUFFER, target.depthBuffer)
+	case DepthTexture:
+		if target.depthTex == nil || target.depthTex.format != format {
+			target.depthTex = NewTextureWithFormat(format)
+		}
+		target.depthTex.Upload(nil, target.width, target.height)
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, attachment, gl.TEXTURE_2D, target.depthTex.glid, 0)
+	}
+}
+
+func (target *Target) checkStatus() {
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		panic(fmt.Errorf("init Framebuffer error: status 0x%X", status))
+	}
+}
+
+// Texture 返回第 0 个颜色附件，多重采样的目标没有纹理，返回 nil，需要先 Resolve 到普通目标
 func (target *Target) Texture() *Texture {
-	return target.tex
+	return target.ColorTexture(0)
+}
+
+func (target *Target) ColorTexture(i int) *Texture {
+	if target.options.Samples > 1 {
+		return nil
+	}
+	return target.colors[i]
+}
+
+func (target *Target) ColorCount() int {
+	return len(target.options.Colors)
+}
+
+func (target *Target) Samples() int {
+	return target.options.Samples
+}
+
+// DepthTexture 返回可采样的深度纹理，深度附件不是 DepthTexture 时为 nil
+func (target *Target) DepthTexture() *Texture {
+	return target.depthTex
+}
+
+func (target *Target) Options() TargetOptions {
+	return target.options
 }
 
+// EnableStencil 把深度附件换成 Depth24Stencil8，没有深度附件时创建 renderbuffer
 func (target *Target) EnableStencil() {
-	if target.stencil > 0 {
+	if target.options.Stencil {
 		return
 	}
-	gl.CreateFramebuffers(1, &target.stencil)
-	gl.BindRenderbuffer(gl.RENDERBUFFER, target.stencil)
+	target.options.Stencil = true
 
-	gl.FramebufferRenderbuffer(gl.RENDERBUFFER, gl.DEPTH_STENCIL_ATTACHMENT, gl.RENDERBUFFER, target.stencil)
-	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_STENCIL, int32(target.width), int32(target.height))
+	target.bind()
+	theContext.dirtyFlag |= dirtyTarget
+	target.attachDepth()
+	target.checkStatus()
+	target.updateBytes()
+	checkError()
 }
 
 func (target *Target) bind() {
@@ -234,6 +1108,7 @@ func (target *Target) bind() {
 
 func (target *Target) Clear(r, g, b, a float32) {
 	target.bind()
+	theContext.dirtyFlag |= dirtyTarget
 	Clear(r, g, b, a)
 }
 
@@ -241,12 +1116,107 @@ func (target *Target) Resize(width, height int) {
 	target.width = width
 	target.height = height
 
-	target.tex.Upload(nil, width, height)
+	target.bind()
+	theContext.dirtyFlag |= dirtyTarget
+	target.attachColors()
+	target.attachDepth()
+	target.updateBytes()
+	checkError()
+}
+
+// Resolve 把多重采样的颜色附件（以及格式相同的深度附件）解析到 dst，两者大小必须一致；
+// dst 为 nil 时把第 0 个颜色附件解析到默认帧缓冲，默认帧缓冲的大小需要和目标一致
+func (target *Target) Resolve(dst *Target) {
+	target.checkBlitDst("resolve", dst)
+	if dst != nil && (dst.width != target.width || dst.height != target.height) {
+		panic(fmt.Errorf("resolve: size %dx%d does not match target %dx%d", dst.width, dst.height, target.width, target.height))
+	}
 
-	if target.stencil > 0 {
-		gl.BindRenderbuffer(gl.RENDERBUFFER, target.stencil)
-		gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_STENCIL, int32(target.width), int32(target.height))
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, target.glid)
+	if dst != nil {
+		gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, dst.glid)
+	} else {
+		gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
 	}
+	theContext.dirtyFlag |= dirtyTarget
+
+	w, h := int32(target.width), int32(target.height)
+	count := 1
+	if dst != nil {
+		count = target.ColorCount()
+		if dst.ColorCount() < count {
+			count = dst.ColorCount()
+		}
+	}
+	for i := 0; i < count; i++ {
+		mask := uint32(gl.COLOR_BUFFER_BIT)
+		if i == 0 && target.hasDepth() && dst != nil && dst.hasDepth() &&
+			target.options.depthFormat() == dst.options.depthFormat() {
+			mask |= gl.DEPTH_BUFFER_BIT
+			if target.options.Stencil {
+				mask |= gl.STENCIL_BUFFER_BIT
+			}
+		}
+		gl.ReadBuffer(gl.COLOR_ATTACHMENT0 + uint32(i))
+		if dst != nil {
+			gl.DrawBuffer(gl.COLOR_ATTACHMENT0 + uint32(i))
+		}
+		gl.BlitFramebuffer(0, 0, w, h, 0, 0, w, h, mask, gl.NEAREST)
+	}
+
+	gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+	if dst != nil {
+		dst.bind()
+		dst.setDrawBuffers()
+		for _, tex := range dst.colors {
+			tex.generateMipmap()
+		}
+	}
+	checkError()
+}
+
+// BlitTo 把第 0 个颜色附件的 srcRect 区域复制并缩放到 dst 的 dstRect，dst 为 nil 时复制到默认帧缓冲；
+// 矩形使用 GL 坐标（左下角为原点），多重采样的目标只能等大复制
+func (target *Target) BlitTo(dst *Target, srcRect, dstRect image.Rectangle, filter FilterMode) {
+	if target.options.Samples > 1 && (srcRect.Dx() != dstRect.Dx() || srcRect.Dy() != dstRect.Dy()) {
+		panic(errors.New("blit: multisample target cannot be scaled, resolve it first"))
+	}
+	target.checkBlitDst("blit", dst)
+
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, target.glid)
+	gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+	if dst != nil {
+		gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, dst.glid)
+	} else {
+		gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
+	}
+	theContext.dirtyFlag |= dirtyTarget
+
+	gl.BlitFramebuffer(int32(srcRect.Min.X), int32(srcRect.Min.Y), int32(srcRect.Max.X), int32(srcRect.Max.Y),
+		int32(dstRect.Min.X), int32(dstRect.Min.Y), int32(dstRect.Max.X), int32(dstRect.Max.Y),
+		gl.COLOR_BUFFER_BIT, uint32(filter.withoutMipmap()))
+
+	if dst != nil {
+		for _, tex := range dst.colors {
+			tex.generateMipmap()
+		}
+	}
+	checkError()
+}
+
+// GL 3.3 中绘制目标是多重采样时 BlitFramebuffer 会产生 INVALID_OPERATION，先给出明确的原因
+func (target *Target) checkBlitDst(op string, dst *Target) {
+	if dst == nil || dst.options.Samples <= 1 {
+		return
+	}
+	if dst.options.Samples != target.options.Samples {
+		panic(fmt.Errorf("%s: destination has %d samples, source has %d", op, dst.options.Samples, target.options.Samples))
+	}
+	panic(fmt.Errorf("%s: destination is multisample, use a single-sample target", op))
+}
+
+func (target *Target) hasDepth() bool {
+	return target.options.Depth != DepthNone || target.options.Stencil
 }
 
 /*
@@ -255,14 +1225,26 @@ func (target *Target) Resize(width, height int) {
 type Shader struct {
 	glid       uint32
 	glvao      uint32
-	attributes map[string]int32
+	attributes []ShaderAttribute
 	uniforms   map[string]int32
 	samplers   []int32
+	//sampler 数组的每个元素各占一个纹理单元
+	samplerNames []string
+	//每个 sampler 需要的纹理类型，gl.TEXTURE_2D、gl.TEXTURE_2D_ARRAY 或 gl.TEXTURE_CUBE_MAP
+	samplerTargets []uint32
+
+	uniformList []ShaderUniform
 
+	attrs        Attrs
 	attribLayout []layout
 	bufferDirty  bool
 	vertexBuffer *Buffer
 	indexBuffer  *Buffer
+	res          *resource
+
+	//上下文丢失后用来重新链接和写回 uniform
+	vertexSrc, fragmentSrc string
+	values                 map[int32][]float32
 }
 
 type layout struct {
@@ -273,7 +1255,7 @@ type layout struct {
 	pointer    unsafe.Pointer
 }
 
-func compileShader(shaderType uint32, source string) uint32 {
+func compileShader(shaderType uint32, source string) (uint32, error) {
 	shader := gl.CreateShader(shaderType)
 
 	cSrc, free := gl.Strs(source + "\x00")
@@ -289,45 +1271,50 @@ func compileShader(shaderType uint32, source string) uint32 {
 
 		log := strings.Repeat("\x00", int(logLength))
 		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+		gl.DeleteShader(shader)
 
-		panic(fmt.Errorf("failed to compile %v: %v", source, log))
+		stage := "vertex"
+		if shaderType == gl.FRAGMENT_SHADER {
+			stage = "fragment"
+		}
+		return 0, newShaderCompileError(stage, source, log)
 	}
-	return shader
+	return shader, nil
 }
 
-func NewShader(vertexSrc, fragmentSrc string, attrs Attrs) *Shader {
-	if len(attrs) == 0 {
-		attrs = theContext.attrs
+func linkProgram(vertexSrc, fragmentSrc string, attrs Attrs) (uint32, error) {
+	var key string
+	cacheDir := theContext.shaderCacheDir
+	if cacheDir != "" && supportsProgramBinary() {
+		key = programCacheKey(driverString(), vertexSrc, fragmentSrc, attrs)
+		if program, ok := loadProgramBinary(cacheDir, key); ok {
+			return program, nil
+		}
 	}
-	vertShader := compileShader(gl.VERTEX_SHADER, vertexSrc)
-	fragShader := compileShader(gl.FRAGMENT_SHADER, fragmentSrc)
 
-	program := gl.CreateProgram()
-	shader := &Shader{
-		glid:         program,
-		attributes:   make(map[string]int32),
-		uniforms:     make(map[string]int32),
-		attribLayout: make([]layout, len(attrs)),
+	vertShader, err := compileShader(gl.VERTEX_SHADER, vertexSrc)
+	if err != nil {
+		return 0, err
 	}
-	gl.GenVertexArrays(1, &shader.glvao)
+	fragShader, err := compileShader(gl.FRAGMENT_SHADER, fragmentSrc)
+	if err != nil {
+		gl.DeleteShader(vertShader)
+		return 0, err
+	}
+
+	program := gl.CreateProgram()
 
 	gl.AttachShader(program, vertShader)
 	gl.DeleteShader(vertShader)
 	gl.AttachShader(program, fragShader)
 	gl.DeleteShader(fragShader)
 
-	offset := uintptr(0)
 	for i, attr := range attrs {
 		gl.BindAttribLocation(program, uint32(i), gl.Str(attr.Name+"\x00"))
-		layout := layout{
-			loc:        uint32(i),
-			num:        int32(attr.Num),
-			xtype:      uint32(attr.Type),
-			normalized: attr.Type.normalized(),
-			pointer:    unsafe.Pointer(offset),
-		}
-		offset += uintptr(attr.Type.size() * attr.Num)
-		shader.attribLayout[i] = layout
+	}
+
+	if key != "" {
+		gl.ProgramParameteri(program, gl.PROGRAM_BINARY_RETRIEVABLE_HINT, gl.TRUE)
 	}
 
 	//BindAttribLocation 必须放在 LinkProgram 之前
@@ -341,40 +1328,232 @@ func NewShader(vertexSrc, fragmentSrc string, attrs Attrs) *Shader {
 
 		log := strings.Repeat("\x00", int(logLength))
 		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		gl.DeleteProgram(program)
+
+		return 0, fmt.Errorf("failed to link program: %v", log)
+	}
 
-		panic(fmt.Errorf("failed to link program: %v", log))
+	if key != "" {
+		saveProgramBinary(cacheDir, key, program)
+	}
+
+	return program, nil
+}
+
+func supportsProgramBinary() bool {
+	var count int32
+	gl.GetIntegerv(gl.NUM_PROGRAM_BINARY_FORMATS, &count)
+	return count > 0
+}
+
+func driverString() string {
+	caps := theContext.caps
+	return caps.Vendor + "|" + caps.Renderer + "|" + caps.Version
+}
+
+// 驱动不接受缓存的二进制（驱动升级等）时返回 false，调用方重新编译
+func loadProgramBinary(cacheDir, key string) (uint32, bool) {
+	format, binary, err := readProgramCache(cacheDir, key)
+	if err != nil || len(binary) == 0 {
+		return 0, false
+	}
+
+	program := gl.CreateProgram()
+	gl.ProgramBinary(program, format, gl.Ptr(binary), int32(len(binary)))
+
+	var status int32
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		gl.DeleteProgram(program)
+		removeProgramCache(cacheDir, key)
+		return 0, false
+	}
+	return program, true
+}
+
+func saveProgramBinary(cacheDir, key string, program uint32) {
+	var length int32
+	gl.GetProgramiv(program, gl.PROGRAM_BINARY_LENGTH, &length)
+	if length <= 0 {
+		return
+	}
+
+	binary := make([]byte, length)
+	var format uint32
+	gl.GetProgramBinary(program, length, &length, &format, gl.Ptr(binary))
+
+	if err := writeProgramCache(cacheDir, key, format, binary[:length]); err != nil {
+		warnf("failed to write program cache: %v", err)
+	}
+}
+
+func NewShader(vertexSrc, fragmentSrc string, attrs Attrs) *Shader {
+	shader, err := newShader(vertexSrc, fragmentSrc, attrs)
+	if err != nil {
+		panic(err)
+	}
+	return shader
+}
+
+func newShader(vertexSrc, fragmentSrc string, attrs Attrs) (*Shader, error) {
+	if len(attrs) == 0 {
+		attrs = theContext.attrs
+	}
+
+	program, err := linkProgram(vertexSrc, fragmentSrc, attrs)
+	if err != nil {
+		return nil, err
+	}
+	attributes := activeAttributes(program)
+	if err := validateAttrs(vertexSrc, attrs, attributes); err != nil {
+		gl.DeleteProgram(program)
+		return nil, err
+	}
+
+	shader := &Shader{
+		glid:         program,
+		attributes:   attributes,
+		uniforms:     make(map[string]int32),
+		attrs:        attrs,
+		attribLayout: make([]layout, len(attrs)),
+		res:          newResource(ResourceShader),
+		vertexSrc:    vertexSrc,
+		fragmentSrc:  fragmentSrc,
+		values:       make(map[int32][]float32),
+	}
+	gl.GenVertexArrays(1, &shader.glvao)
+	setRestore(shader.res, shader, (*Shader).restore)
+
+	offset := uintptr(0)
+	for i, attr := range attrs {
+		layout := layout{
+			loc:        uint32(i),
+			num:        int32(attr.Num),
+			xtype:      uint32(attr.Type),
+			normalized: attr.Type.normalized(),
+			pointer:    unsafe.Pointer(offset),
+		}
+		offset += uintptr(attr.Type.size() * attr.Num)
+		shader.attribLayout[i] = layout
 	}
 
-	shader.getAttributes()
 	shader.getUniforms()
+	checkError()
 
 	runtime.SetFinalizer(shader, (*Shader).delete)
 
-	return shader
+	return shader, nil
+}
+
+func LoadShader(vertexFile, fragmentFile string, attrs Attrs) (*Shader, error) {
+	vertexSrc, err := os.ReadFile(vertexFile)
+	if err != nil {
+		return nil, err
+	}
+	fragmentSrc, err := os.ReadFile(fragmentFile)
+	if err != nil {
+		return nil, err
+	}
+	return newShader(string(vertexSrc), string(fragmentSrc), attrs)
 }
 
 func (shader *Shader) delete() {
-	gl.DeleteShader(shader.glid)
+	gl.DeleteProgram(shader.glid)
 	gl.DeleteVertexArrays(1, &shader.glvao)
+	shader.res.release()
 }
 
-func (shader *Shader) getAttributes() {
-	program := shader.glid
+// SetLabel 设置在 Resources 中显示的名字
+func (shader *Shader) SetLabel(label string) {
+	shader.res.setLabel(label)
+	objectLabel(gl.PROGRAM, shader.glid, label)
+	gl.BindVertexArray(shader.glvao)
+	objectLabel(gl.VERTEX_ARRAY, shader.glvao, label)
+	checkError()
+}
+
+func (shader *Shader) Label() string {
+	return shader.res.getLabel()
+}
+
+// Reload 重新编译并替换 program，保留 uniform 的值；编译失败时继续使用旧的 program
+func (shader *Shader) Reload(vertexSrc, fragmentSrc string) error {
+	program, err := linkProgram(vertexSrc, fragmentSrc, shader.attrs)
+	if err != nil {
+		return err
+	}
+	attributes := activeAttributes(program)
+	if err := validateAttrs(vertexSrc, shader.attrs, attributes); err != nil {
+		gl.DeleteProgram(program)
+		return err
+	}
+
+	values := shader.saveUniforms()
+
+	gl.DeleteProgram(shader.glid)
+	shader.vertexSrc = vertexSrc
+	shader.fragmentSrc = fragmentSrc
+	shader.replaceProgram(program, attributes, values)
+
+	//恢复当前使用的 program
+	if c := theContext; c.shader != shader {
+		if c.shader != nil {
+			gl.UseProgram(c.shader.glid)
+		} else {
+			gl.UseProgram(0)
+		}
+	} else {
+		c.dirtyFlag |= dirtyTexture
+	}
+	checkError()
 
+	return nil
+}
+
+// 替换 program 并按名字写回 uniform，之后当前使用的 program 是 shader 的
+func (shader *Shader) replaceProgram(program uint32, attributes []ShaderAttribute, values map[string][]float32) {
+	shader.glid = program
+	shader.attributes = attributes
+	shader.uniforms = make(map[string]int32)
+	shader.uniformList = nil
+	shader.samplers = nil
+	shader.samplerNames = nil
+	shader.samplerTargets = nil
+	shader.values = make(map[int32][]float32)
+	shader.getUniforms()
+	if label := shader.Label(); label != "" {
+		objectLabel(gl.PROGRAM, shader.glid, label)
+	}
+
+	gl.UseProgram(shader.glid)
+	shader.restoreUniforms(values)
+	shader.applyTextureUniform()
+}
+
+func activeAttributes(program uint32) []ShaderAttribute {
 	var count int32
 	gl.GetProgramiv(program, gl.ACTIVE_ATTRIBUTES, &count)
 
 	var length, maxLength int32
 	gl.GetProgramiv(program, gl.ACTIVE_ATTRIBUTE_MAX_LENGTH, &maxLength)
 
-	data := make([]uint8, maxLength)
+	data := make([]uint8, maxLength+1)
+	var xtype uint32
+	var size int32
 
+	attributes := make([]ShaderAttribute, 0, count)
 	for i := int32(0); i < count; i++ {
-		gl.GetActiveAttrib(program, uint32(i), maxLength, &length, nil, nil, &data[0])
+		gl.GetActiveAttrib(program, uint32(i), maxLength, &length, &size, &xtype, &data[0])
 		loc := gl.GetAttribLocation(program, &data[0])
-		name := string(data[:length])
-		shader.attributes[name] = loc
+		attributes = append(attributes, ShaderAttribute{
+			Name:       string(data[:length]),
+			Location:   loc,
+			Type:       xtype,
+			Size:       size,
+			Components: glslComponents(xtype),
+		})
 	}
+	return attributes
 }
 
 func (shader *Shader) getUniforms() {
@@ -388,16 +1567,123 @@ func (shader *Shader) getUniforms() {
 
 	data := make([]uint8, maxLength)
 	var xtype uint32
+	var size int32
 
 	for i := int32(0); i < count; i++ {
-		gl.GetActiveUniform(program, uint32(i), maxLength, &length, nil, &xtype, &data[0])
+		gl.GetActiveUniform(program, uint32(i), maxLength, &length, &size, &xtype, &data[0])
 		loc := gl.GetUniformLocation(program, &data[0])
 		name := string(data[:length])
 		shader.uniforms[name] = loc
-		if xtype == gl.SAMPLER_2D {
-			shader.samplers = append(shader.samplers, loc)
+		shader.uniformList = append(shader.uniformList, ShaderUniform{name, loc, xtype, size})
+		if target, ok := samplerTarget(xtype); ok {
+			base := strings.TrimSuffix(name, "[0]")
+			for k := int32(0); k < size; k++ {
+				samplerName, samplerLoc := name, loc
+				if size > 1 {
+					samplerName = fmt.Sprintf("%s[%d]", base, k)
+					samplerLoc = gl.GetUniformLocation(program, gl.Str(samplerName+"\x00"))
+				}
+				shader.samplers = append(shader.samplers, samplerLoc)
+				shader.samplerNames = append(shader.samplerNames, samplerName)
+				shader.samplerTargets = append(shader.samplerTargets, target)
+			}
+		}
+	}
+}
+
+func samplerTarget(xtype uint32) (uint32, bool) {
+	switch xtype {
+	case gl.SAMPLER_2D, gl.SAMPLER_2D_SHADOW, gl.INT_SAMPLER_2D, gl.UNSIGNED_INT_SAMPLER_2D:
+		return gl.TEXTURE_2D, true
+	case gl.SAMPLER_2D_ARRAY:
+		return gl.TEXTURE_2D_ARRAY, true
+	case gl.SAMPLER_CUBE:
+		return gl.TEXTURE_CUBE_MAP, true
+	default:
+		return 0, false
+	}
+}
+
+// 读取 uniform 当前的值，数组 uniform 按元素读取
+func (shader *Shader) saveUniforms() map[string][]float32 {
+	values := make(map[string][]float32)
+	for _, u := range shader.uniformList {
+		num := uniformComponents(u.Type)
+		if num == 0 {
+			continue
+		}
+		base := strings.TrimSuffix(u.Name, "[0]")
+		for i := int32(0); i < u.Size; i++ {
+			name, loc := u.Name, u.Location
+			if u.Size > 1 {
+				name = fmt.Sprintf("%s[%d]", base, i)
+				loc = gl.GetUniformLocation(shader.glid, gl.Str(name+"\x00"))
+			}
+			v := make([]float32, num)
+			gl.GetUniformfv(shader.glid, loc, &v[0])
+			values[name] = v
 		}
 	}
+	return values
+}
+
+// 按名字写回 uniform 的值，调用前需要先 UseProgram
+func (shader *Shader) restoreUniforms(values map[string][]float32) {
+	for name, v := range values {
+		loc := gl.GetUniformLocation(shader.glid, gl.Str(name+"\x00"))
+		if loc < 0 {
+			continue
+		}
+		shader.SetUniform(loc, v...)
+	}
+}
+
+// 顶点属性的 GLSL 类型对应的分量数
+func glslComponents(xtype uint32) int {
+	switch xtype {
+	case gl.FLOAT, gl.INT, gl.UNSIGNED_INT, gl.BOOL:
+		return 1
+	case gl.FLOAT_VEC2, gl.INT_VEC2, gl.UNSIGNED_INT_VEC2, gl.BOOL_VEC2:
+		return 2
+	case gl.FLOAT_VEC3, gl.INT_VEC3, gl.UNSIGNED_INT_VEC3, gl.BOOL_VEC3:
+		return 3
+	case gl.FLOAT_VEC4, gl.INT_VEC4, gl.UNSIGNED_INT_VEC4, gl.BOOL_VEC4, gl.FLOAT_MAT2:
+		return 4
+	case gl.FLOAT_MAT3:
+		return 9
+	case gl.FLOAT_MAT4:
+		return 16
+	default:
+		return 0
+	}
+}
+
+// 浮点 uniform 的分量数，其他类型返回 0
+func uniformComponents(xtype uint32) int {
+	switch xtype {
+	case gl.FLOAT:
+		return 1
+	case gl.FLOAT_VEC2:
+		return 2
+	case gl.FLOAT_VEC3:
+		return 3
+	case gl.FLOAT_VEC4:
+		return 4
+	case gl.FLOAT_MAT3:
+		return 9
+	case gl.FLOAT_MAT4:
+		return 16
+	default:
+		return 0
+	}
+}
+
+func (shader *Shader) Attributes() []ShaderAttribute {
+	return shader.attributes
+}
+
+func (shader *Shader) Uniforms() []ShaderUniform {
+	return shader.uniformList
 }
 
 func (shader *Shader) SetVertexBuffer(vertexBuffer *Buffer) {
@@ -425,6 +1711,7 @@ func (shader *Shader) IndexBuffer() *Buffer {
 func (shader *Shader) bind() {
 	gl.UseProgram(shader.glid)
 	shader.applyTextureUniform()
+	checkError()
 }
 
 func (shader *Shader) applyVertex() {
@@ -461,6 +1748,12 @@ func (shader *Shader) SetUniformName(name string, v ...float32) {
 }
 
 func (shader *Shader) SetUniform(loc int32, v ...float32) {
+	if old, ok := shader.values[loc]; ok && len(old) == len(v) {
+		copy(old, v)
+	} else {
+		shader.values[loc] = append([]float32(nil), v...)
+	}
+
 	switch len(v) {
 	case 1: //gl.FLOAT:
 		gl.Uniform1f(loc, v[0])
@@ -477,6 +1770,7 @@ func (shader *Shader) SetUniform(loc int32, v ...float32) {
 	default:
 		panic("error uniform type")
 	}
+	checkError()
 }
 
 /*
@@ -484,11 +1778,31 @@ func (shader *Shader) SetUniform(loc int32, v ...float32) {
  */
 func Clear(r, g, b, a float32) {
 	gl.ClearColor(r, g, b, a)
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
+	checkError()
 }
 
 func Viewport(x, y, width, height int) {
+	theContext.viewport = [4]int{x, y, width, height}
 	gl.Viewport(int32(x), int32(y), int32(width), int32(height))
+	checkError()
+}
+
+// 其它代码可能留下影响上传、读取和绘制的状态，恢复成这里假定的默认值；
+// 裁剪框恢复为整个视口
+func resetState(viewport [4]int) {
+	gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, 0)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, 0)
+	gl.PixelStorei(gl.UNPACK_SWAP_BYTES, gl.FALSE)
+	gl.PixelStorei(gl.PACK_ROW_LENGTH, 0)
+
+	gl.BlendEquation(gl.FUNC_ADD)
+	gl.ColorMask(true, true, true, true)
+	gl.Disable(gl.CULL_FACE)
+	gl.Disable(gl.STENCIL_TEST)
+	gl.Disable(gl.FRAMEBUFFER_SRGB)
+	gl.Scissor(int32(viewport[0]), int32(viewport[1]), int32(viewport[2]), int32(viewport[3]))
 }
 
 func enable(cap CapType) {
@@ -513,4 +1827,5 @@ func depthMask(flag bool) {
 
 func glDraw(start, count int) {
 	gl.DrawElements(gl.TRIANGLES, int32(count), gl.UNSIGNED_SHORT, unsafe.Pointer(uintptr(start)))
+	checkError()
 }
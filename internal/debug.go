@@ -0,0 +1,37 @@
+package internal
+
+import "log"
+
+// Logger 接收 pixi 的警告和 GL 调试信息，*log.Logger 满足这个接口
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type defaultLogger struct{}
+
+func (defaultLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+var (
+	logger Logger = defaultLogger{}
+	//使用 pixidebug 编译时默认开启
+	debugMode = debugBuild
+)
+
+// SetLogger 替换输出警告和调试信息的 Logger，nil 时恢复为标准库 log
+func SetLogger(l Logger) {
+	if l == nil {
+		l = defaultLogger{}
+	}
+	logger = l
+}
+
+// IsDebug 返回是否开启了调试模式
+func IsDebug() bool {
+	return debugMode
+}
+
+func warnf(format string, v ...interface{}) {
+	logger.Printf("pixi: "+format, v...)
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"unsafe"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+/*
+ *	Debug
+ */
+
+var glErrorNames = map[uint32]string{
+	gl.INVALID_ENUM:                  "GL_INVALID_ENUM",
+	gl.INVALID_VALUE:                 "GL_INVALID_VALUE",
+	gl.INVALID_OPERATION:             "GL_INVALID_OPERATION",
+	gl.STACK_OVERFLOW:                "GL_STACK_OVERFLOW",
+	gl.STACK_UNDERFLOW:               "GL_STACK_UNDERFLOW",
+	gl.OUT_OF_MEMORY:                 "GL_OUT_OF_MEMORY",
+	gl.INVALID_FRAMEBUFFER_OPERATION: "GL_INVALID_FRAMEBUFFER_OPERATION",
+}
+
+// checkError 在调试模式下读取 glGetError，报告出错的 pixi 函数和调用它的用户代码位置
+func checkError() {
+	if !debugMode {
+		return
+	}
+	for {
+		code := gl.GetError()
+		if code == gl.NO_ERROR {
+			return
+		}
+		name, ok := glErrorNames[code]
+		if !ok {
+			name = fmt.Sprintf("0x%X", code)
+		}
+		fn, site := errorSite()
+		warnf("%s in %s, called from %s", name, fn, site)
+	}
+}
+
+// 返回调用 checkError 的函数，以及调用栈中第一个不在本包内的位置
+func errorSite() (string, string) {
+	pcs := make([]uintptr, 32)
+	//跳过 runtime.Callers、errorSite 和 checkError
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	fn, site := "", "unknown"
+	for {
+		frame, more := frames.Next()
+		if fn == "" {
+			fn = frame.Function
+		}
+		if !strings.Contains(frame.Function, "/internal.") {
+			site = fmt.Sprintf("%s:%d", frame.File, frame.Line)
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	return fn, site
+}
+
+// 需要在创建窗口时请求调试上下文，驱动才会输出完整的信息
+func installDebugCallback() {
+	if !debugMode || !hasExtension("GL_KHR_debug") {
+		return
+	}
+	gl.Enable(gl.DEBUG_OUTPUT)
+	gl.Enable(gl.DEBUG_OUTPUT_SYNCHRONOUS)
+	gl.DebugMessageCallback(debugMessage, nil)
+	//忽略大量的通知级别信息
+	gl.DebugMessageControl(gl.DONT_CARE, gl.DONT_CARE, gl.DEBUG_SEVERITY_NOTIFICATION, 0, nil, false)
+}
+
+func debugMessage(source uint32, xtype uint32, id uint32, severity uint32, length int32, message string, userParam unsafe.Pointer) {
+	level := "low"
+	switch severity {
+	case gl.DEBUG_SEVERITY_HIGH:
+		level = "high"
+	case gl.DEBUG_SEVERITY_MEDIUM:
+		level = "medium"
+	case gl.DEBUG_SEVERITY_NOTIFICATION:
+		level = "notification"
+	}
+	logger.Printf("pixi: gl debug [%s] id=%d: %s", level, id, strings.TrimSpace(message))
+}
+
+// 给 GL 对象设置名字，RenderDoc 等工具和调试信息中会显示；对象需要已经绑定过，否则是 GL_INVALID_VALUE
+func objectLabel(identifier, glid uint32, label string) {
+	if glid == 0 || !hasExtension("GL_KHR_debug") {
+		return
+	}
+	gl.ObjectLabel(identifier, glid, -1, gl.Str(label+"\x00"))
+}
@@ -0,0 +1,6 @@
+//go:build pixidebug
+// +build pixidebug
+
+package internal
+
+const debugBuild = true
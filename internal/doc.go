@@ -0,0 +1,5 @@
+// Package internal 是 pixi 的 OpenGL 3.3 封装。
+//
+// 需要 Go 1.24 及以上版本：上下文恢复使用的资源登记表通过 weak 包保存资源的弱引用，不会阻止资源被回收，
+// restore_desktop.go 带有 go1.24 的构建约束。
+package internal
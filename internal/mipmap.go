@@ -0,0 +1,270 @@
+package internal
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"math"
+)
+
+type MipFilter int
+
+const (
+	MipBox MipFilter = iota
+	MipLanczos
+)
+
+const lanczosRadius = 3
+
+// 完整 mip 链的层数
+func mipLevels(width, height int) int {
+	levels := 1
+	for width > 1 || height > 1 {
+		width /= 2
+		height /= 2
+		levels++
+	}
+	return levels
+}
+
+var srgbToLinearTable = func() [256]float32 {
+	var table [256]float32
+	for i := range table {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			table[i] = float32(c / 12.92)
+		} else {
+			table[i] = float32(math.Pow((c+0.055)/1.055, 2.4))
+		}
+	}
+	return table
+}()
+
+func linearToSRGB(c float32) float32 {
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return float32(1.055*math.Pow(float64(c), 1/2.4) - 0.055)
+}
+
+// 预乘 alpha 的浮点图像，gamma 为 true 时颜色在线性空间
+type mipImage struct {
+	width, height int
+	pix           []float32
+}
+
+// GenerateMipChain 在 CPU 上生成完整的 mip 链，第 0 层为原图（预乘 alpha）；
+// gamma 为 true 时把颜色当作 sRGB，在线性空间中滤波；图片为空时返回错误
+func GenerateMipChain(img image.Image, filter MipFilter, gamma bool) ([]*image.RGBA, error) {
+	if img.Bounds().Empty() {
+		return nil, errors.New("generate mip chain: empty image")
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	levels := []*image.RGBA{rgba}
+	src := newMipImage(rgba, gamma)
+	for src.width > 1 || src.height > 1 {
+		var dst *mipImage
+		if filter == MipLanczos {
+			dst = src.downsampleLanczos()
+		} else {
+			dst = src.downsampleBox()
+		}
+		levels = append(levels, dst.toRGBA(gamma))
+		src = dst
+	}
+	return levels, nil
+}
+
+func newMipImage(img *image.RGBA, gamma bool) *mipImage {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	m := &mipImage{width: width, height: height, pix: make([]float32, width*height*4)}
+	for y := 0; y < height; y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < width; x++ {
+			s := row[x*4 : x*4+4]
+			d := m.pix[(y*width+x)*4:]
+			a := float32(s[3]) / 255
+			d[3] = a
+			for i := 0; i < 3; i++ {
+				if !gamma {
+					d[i] = float32(s[i]) / 255
+				} else if s[3] > 0 {
+					//sRGB 转换要在非预乘的颜色上进行
+					straight := int(float32(s[i])/a + 0.5)
+					if straight > 255 {
+						straight = 255
+					}
+					d[i] = srgbToLinearTable[straight] * a
+				}
+			}
+		}
+	}
+	return m
+}
+
+func (m *mipImage) toRGBA(gamma bool) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, m.width, m.height))
+	for i := 0; i < m.width*m.height; i++ {
+		s := m.pix[i*4 : i*4+4]
+		d := img.Pix[i*4 : i*4+4]
+		a := clamp01(s[3])
+		d[3] = uint8(a*255 + 0.5)
+		for c := 0; c < 3; c++ {
+			v := clamp01(s[c])
+			if gamma && a > 0 {
+				v = linearToSRGB(clamp01(v/a)) * a
+			}
+			if v > a {
+				v = a
+			}
+			d[c] = uint8(v*255 + 0.5)
+		}
+	}
+	return img
+}
+
+func clamp01(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+func halfSize(n int) int {
+	if n > 1 {
+		return n / 2
+	}
+	return 1
+}
+
+// 2x2 平均；奇数尺寸时每个目标像素覆盖 3 个源像素，按覆盖面积加权，不丢弃边缘
+func (m *mipImage) downsampleBox() *mipImage {
+	width, height := halfSize(m.width), halfSize(m.height)
+	dst := &mipImage{width: width, height: height, pix: make([]float32, width*height*4)}
+	weightsX, offsetsX := boxWeights(m.width, width)
+	weightsY, offsetsY := boxWeights(m.height, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			d := dst.pix[(y*width+x)*4:]
+			for j, wy := range weightsY[y] {
+				for i, wx := range weightsX[x] {
+					w := wx * wy
+					for c := 0; c < 4; c++ {
+						d[c] += m.at(offsetsX[x]+i, offsetsY[y]+j, c) * w
+					}
+				}
+			}
+		}
+	}
+	return dst
+}
+
+// 每个目标像素对应的源像素起点和权重，权重为目标像素在源像素上的覆盖面积
+func boxWeights(srcLen, dstLen int) ([][]float32, []int) {
+	weights := make([][]float32, dstLen)
+	offsets := make([]int, dstLen)
+	for i := 0; i < dstLen; i++ {
+		switch {
+		case srcLen == 1:
+			weights[i] = []float32{1}
+		case srcLen%2 == 0:
+			offsets[i] = i * 2
+			weights[i] = []float32{0.5, 0.5}
+		default:
+			n := float32(srcLen)
+			offsets[i] = i * 2
+			weights[i] = []float32{float32(dstLen-i) / n, float32(dstLen) / n, float32(i+1) / n}
+		}
+	}
+	return weights, offsets
+}
+
+// 可分离的 Lanczos3 缩小一半，先水平再垂直
+func (m *mipImage) downsampleLanczos() *mipImage {
+	width, height := halfSize(m.width), halfSize(m.height)
+
+	tmp := &mipImage{width: width, height: m.height, pix: make([]float32, width*m.height*4)}
+	weights, offsets := lanczosWeights(m.width, width)
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < width; x++ {
+			d := tmp.pix[(y*width+x)*4:]
+			for i, w := range weights[x] {
+				sx := clampIndex(offsets[x]+i, m.width)
+				for c := 0; c < 4; c++ {
+					d[c] += m.at(sx, y, c) * w
+				}
+			}
+		}
+	}
+
+	dst := &mipImage{width: width, height: height, pix: make([]float32, width*height*4)}
+	weights, offsets = lanczosWeights(m.height, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			d := dst.pix[(y*width+x)*4:]
+			for i, w := range weights[y] {
+				sy := clampIndex(offsets[y]+i, m.height)
+				for c := 0; c < 4; c++ {
+					d[c] += tmp.at(x, sy, c) * w
+				}
+			}
+		}
+	}
+	return dst
+}
+
+func (m *mipImage) at(x, y, c int) float32 {
+	return m.pix[(y*m.width+x)*4+c]
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+func lanczos(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	if x <= -lanczosRadius || x >= lanczosRadius {
+		return 0
+	}
+	px := math.Pi * x
+	return lanczosRadius * math.Sin(px) * math.Sin(px/lanczosRadius) / (px * px)
+}
+
+// 每个目标像素对应的源像素起点和归一化的权重
+func lanczosWeights(srcLen, dstLen int) ([][]float32, []int) {
+	scale := float64(srcLen) / float64(dstLen)
+	support := lanczosRadius * scale
+	weights := make([][]float32, dstLen)
+	offsets := make([]int, dstLen)
+	for i := 0; i < dstLen; i++ {
+		center := (float64(i) + 0.5) * scale
+		start := int(math.Floor(center - support))
+		end := int(math.Ceil(center + support))
+		offsets[i] = start
+
+		var sum float64
+		w := make([]float64, end-start)
+		for j := range w {
+			w[j] = lanczos((float64(start+j) + 0.5 - center) / scale)
+			sum += w[j]
+		}
+		weights[i] = make([]float32, len(w))
+		for j := range w {
+			weights[i][j] = float32(w[j] / sum)
+		}
+	}
+	return weights, offsets
+}
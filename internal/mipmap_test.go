@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestGenerateMipChainSizes(t *testing.T) {
+	tests := []struct {
+		width, height int
+		sizes         []image.Point
+	}{
+		{1, 1, []image.Point{{1, 1}}},
+		{4, 4, []image.Point{{4, 4}, {2, 2}, {1, 1}}},
+		{5, 3, []image.Point{{5, 3}, {2, 1}, {1, 1}}},
+		{7, 1, []image.Point{{7, 1}, {3, 1}, {1, 1}}},
+		{1, 9, []image.Point{{1, 9}, {1, 4}, {1, 2}, {1, 1}}},
+	}
+	for _, test := range tests {
+		for _, filter := range []MipFilter{MipBox, MipLanczos} {
+			levels, err := GenerateMipChain(image.NewRGBA(image.Rect(0, 0, test.width, test.height)), filter, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(levels) != len(test.sizes) || len(levels) != mipLevels(test.width, test.height) {
+				t.Errorf("%dx%d filter %d: %d levels, want %d", test.width, test.height, filter, len(levels), len(test.sizes))
+				continue
+			}
+			for i, level := range levels {
+				if level.Rect.Size() != test.sizes[i] {
+					t.Errorf("%dx%d filter %d: level %d is %v, want %v", test.width, test.height, filter, i, level.Rect.Size(), test.sizes[i])
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateMipChainConstantColor(t *testing.T) {
+	c := color.RGBA{200, 100, 50, 255}
+	half := color.RGBA{60, 30, 10, 128}
+	for _, fill := range []color.RGBA{c, half} {
+		img := image.NewRGBA(image.Rect(0, 0, 9, 7))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = fill.R, fill.G, fill.B, fill.A
+		}
+		for _, filter := range []MipFilter{MipBox, MipLanczos} {
+			for _, gamma := range []bool{false, true} {
+				levels, err := GenerateMipChain(img, filter, gamma)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i, level := range levels {
+					for p := 0; p < len(level.Pix); p++ {
+						want := img.Pix[p%4]
+						if d := int(level.Pix[p]) - int(want); d < -1 || d > 1 {
+							t.Errorf("%v filter %d gamma %v: level %d byte %d = %d, want %d",
+								fill, filter, gamma, i, p, level.Pix[p], want)
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateMipChainSRGB(t *testing.T) {
+	//黑白各半：线性空间平均为 0.5，转回 sRGB 为 188；直接平均为 128
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	copy(img.Pix, []uint8{
+		0, 0, 0, 255, 255, 255, 255, 255,
+		255, 255, 255, 255, 0, 0, 0, 255,
+	})
+	for _, test := range []struct {
+		gamma bool
+		want  uint8
+	}{{true, 188}, {false, 128}} {
+		levels, err := GenerateMipChain(img, MipBox, test.gamma)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := levels[1].Pix
+		if got[0] != test.want || got[1] != test.want || got[2] != test.want || got[3] != 255 {
+			t.Errorf("gamma %v: 1x1 level = %v, want %d", test.gamma, got, test.want)
+		}
+	}
+}
+
+func TestGenerateMipChainEmpty(t *testing.T) {
+	if _, err := GenerateMipChain(image.NewRGBA(image.Rect(0, 0, 0, 0)), MipBox, false); err == nil {
+		t.Error("empty image did not return an error")
+	}
+}
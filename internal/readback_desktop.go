@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"runtime"
+	"unsafe"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+/*
+ *	Readback
+ */
+
+// ReadPixels 读取 rect 区域的像素，rect 使用图片坐标（左上角为原点），返回的图片已经上下翻转；
+// target 为 nil 时读取窗口的默认帧缓冲（截图），需要先用 SetFramebufferSize 设置它的大小
+func (target *Target) ReadPixels(rect image.Rectangle) (*image.RGBA, error) {
+	if err := target.checkReadRect(rect); err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	target.readPixels(rect, gl.Ptr(img.Pix))
+	checkError()
+	flipRows(img.Pix, img.Stride, rect.Dy())
+
+	return img, nil
+}
+
+// ReadPixelsAsync 通过 PBO 异步读取像素，不会等待 GPU 完成绘制
+func (target *Target) ReadPixelsAsync(rect image.Rectangle) (*PixelFuture, error) {
+	if err := target.checkReadRect(rect); err != nil {
+		return nil, err
+	}
+
+	future := &PixelFuture{rect: rect}
+	gl.GenBuffers(1, &future.pbo)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, future.pbo)
+	gl.BufferData(gl.PIXEL_PACK_BUFFER, rect.Dx()*rect.Dy()*4, nil, gl.STREAM_READ)
+
+	//绑定了 PIXEL_PACK_BUFFER 时最后一个参数是缓冲区内的偏移
+	target.readPixels(rect, nil)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+
+	future.fence = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+	checkError()
+
+	runtime.SetFinalizer(future, (*PixelFuture).delete)
+
+	return future, nil
+}
+
+func (target *Target) checkReadRect(rect image.Rectangle) error {
+	if target != nil && target.options.Samples > 1 {
+		return errors.New("read pixels: multisample target, resolve it first")
+	}
+	if rect.Empty() {
+		return errors.New("read pixels: empty rect")
+	}
+	width, height := target.readSize()
+	if width == 0 || height == 0 {
+		return errors.New("read pixels: default framebuffer size unknown, call SetFramebufferSize")
+	}
+	if !rect.In(image.Rect(0, 0, width, height)) {
+		return fmt.Errorf("read pixels: rect %v out of target bounds %dx%d", rect, width, height)
+	}
+	return nil
+}
+
+func (target *Target) readSize() (int, int) {
+	if target != nil {
+		return target.width, target.height
+	}
+	return theContext.fbWidth, theContext.fbHeight
+}
+
+func (target *Target) readPixels(rect image.Rectangle, ptr unsafe.Pointer) {
+	var glid uint32
+	if target != nil {
+		glid = target.glid
+	}
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, glid)
+	theContext.dirtyFlag |= dirtyTarget
+
+	_, height := target.readSize()
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(int32(rect.Min.X), int32(height-rect.Max.Y), int32(rect.Dx()), int32(rect.Dy()),
+		gl.RGBA, gl.UNSIGNED_BYTE, ptr)
+}
+
+// Download 读取 2D 纹理第 0 层的像素，行的顺序与上传时一致；仅支持 8 位 RGBA 格式，
+// AlphaStraight 的纹理返回 *image.NRGBA，否则返回 *image.RGBA
+func (tex *Texture) Download() (image.Image, error) {
+	if tex.gltarget != gl.TEXTURE_2D {
+		return nil, errors.New("download texture: only 2D textures are supported")
+	}
+	if tex.format != FormatRGBA8 && tex.format != FormatSRGB8Alpha8 {
+		return nil, errors.New("download texture: unsupported format")
+	}
+	if tex.width == 0 || tex.height == 0 {
+		return nil, errors.New("download texture: empty texture")
+	}
+
+	pix := make([]uint8, tex.width*tex.height*4)
+	tex.bind()
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.GetTexImage(gl.TEXTURE_2D, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pix))
+	checkError()
+
+	rect := image.Rect(0, 0, tex.width, tex.height)
+	if tex.alpha == AlphaStraight {
+		return &image.NRGBA{Pix: pix, Stride: tex.width * 4, Rect: rect}, nil
+	}
+	return &image.RGBA{Pix: pix, Stride: tex.width * 4, Rect: rect}, nil
+}
+
+// PixelFuture 异步读取的结果，需要在渲染线程中调用
+type PixelFuture struct {
+	pbo   uint32
+	fence uintptr
+	rect  image.Rectangle
+	img   *image.RGBA
+	err   error
+}
+
+func (future *PixelFuture) delete() {
+	if future.fence != 0 {
+		gl.DeleteSync(future.fence)
+		future.fence = 0
+	}
+	if future.pbo != 0 {
+		gl.DeleteBuffers(1, &future.pbo)
+		future.pbo = 0
+	}
+}
+
+// Ready 不阻塞地检查 GPU 是否已经完成读取
+func (future *PixelFuture) Ready() bool {
+	if future.fence == 0 {
+		return true
+	}
+	switch gl.ClientWaitSync(future.fence, 0, 0) {
+	case gl.ALREADY_SIGNALED, gl.CONDITION_SATISFIED:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait 阻塞直到读取完成并返回图片，可以重复调用
+func (future *PixelFuture) Wait() (*image.RGBA, error) {
+	if future.fence == 0 {
+		return future.img, future.err
+	}
+
+	for {
+		status := gl.ClientWaitSync(future.fence, gl.SYNC_FLUSH_COMMANDS_BIT, 1e6)
+		if status == gl.WAIT_FAILED {
+			future.err = errors.New("read pixels: wait sync failed")
+			break
+		}
+		if status != gl.TIMEOUT_EXPIRED {
+			future.img = future.mapPixels()
+			break
+		}
+	}
+
+	future.delete()
+	runtime.SetFinalizer(future, nil)
+
+	return future.img, future.err
+}
+
+func (future *PixelFuture) mapPixels() *image.RGBA {
+	width, height := future.rect.Dx(), future.rect.Dy()
+	size := width * height * 4
+
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, future.pbo)
+	ptr := gl.MapBufferRange(gl.PIXEL_PACK_BUFFER, 0, size, gl.MAP_READ_BIT)
+	if ptr == nil {
+		gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+		future.err = errors.New("read pixels: map buffer failed")
+		return nil
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	copy(img.Pix, unsafe.Slice((*byte)(ptr), size))
+	gl.UnmapBuffer(gl.PIXEL_PACK_BUFFER)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+	checkError()
+
+	flipRows(img.Pix, img.Stride, height)
+	return img
+}
+
+// OpenGL 的原点在左下角，翻转成图片的行顺序
+func flipRows(pix []byte, stride, height int) {
+	tmp := make([]byte, stride)
+	for top, bottom := 0, height-1; top < bottom; top, bottom = top+1, bottom-1 {
+		a := pix[top*stride : top*stride+stride]
+		b := pix[bottom*stride : bottom*stride+stride]
+		copy(tmp, a)
+		copy(a, b)
+		copy(b, tmp)
+	}
+}
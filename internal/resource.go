@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ResourceKind GPU 资源的类型
+type ResourceKind int
+
+const (
+	ResourceBuffer ResourceKind = iota
+	ResourceTexture
+	ResourceTarget
+	ResourceShader
+	ResourceSampler
+)
+
+func (kind ResourceKind) String() string {
+	switch kind {
+	case ResourceBuffer:
+		return "Buffer"
+	case ResourceTexture:
+		return "Texture"
+	case ResourceTarget:
+		return "Target"
+	case ResourceShader:
+		return "Shader"
+	case ResourceSampler:
+		return "Sampler"
+	default:
+		return fmt.Sprintf("ResourceKind(%d)", int(kind))
+	}
+}
+
+// ResourceInfo 是 Resources 返回的资源快照；Bytes 为估算的显存大小，
+// Target 只计算自己的 renderbuffer，颜色和深度纹理作为 Texture 单独列出
+type ResourceInfo struct {
+	Kind  ResourceKind
+	Bytes int64
+	Label string
+	Stack string
+}
+
+// 资源在登记表中的条目，不引用资源本身，资源被回收时在 delete 中移除；
+// restore 通过弱引用找到资源并在上下文丢失后重建
+type resource struct {
+	kind    ResourceKind
+	bytes   int64
+	label   string
+	stack   []uintptr
+	restore func()
+}
+
+var registry = struct {
+	sync.Mutex
+	entries map[*resource]struct{}
+}{entries: make(map[*resource]struct{})}
+
+const resourceStackDepth = 32
+
+func newResource(kind ResourceKind) *resource {
+	res := &resource{kind: kind}
+	pcs := make([]uintptr, resourceStackDepth)
+	//跳过 runtime.Callers 和 newResource
+	res.stack = pcs[:runtime.Callers(2, pcs)]
+
+	registry.Lock()
+	registry.entries[res] = struct{}{}
+	registry.Unlock()
+	return res
+}
+
+func (res *resource) release() {
+	registry.Lock()
+	delete(registry.entries, res)
+	registry.Unlock()
+}
+
+func (res *resource) setBytes(bytes int64) {
+	registry.Lock()
+	res.bytes = bytes
+	registry.Unlock()
+}
+
+func (res *resource) setLabel(label string) {
+	registry.Lock()
+	res.label = label
+	registry.Unlock()
+}
+
+func (res *resource) getLabel() string {
+	registry.Lock()
+	defer registry.Unlock()
+	return res.label
+}
+
+// Resources 返回所有存活资源的快照，按 Bytes 从大到小排列
+func Resources() []ResourceInfo {
+	registry.Lock()
+	infos := make([]ResourceInfo, 0, len(registry.entries))
+	stacks := make([][]uintptr, 0, len(registry.entries))
+	for res := range registry.entries {
+		infos = append(infos, ResourceInfo{Kind: res.kind, Bytes: res.bytes, Label: res.label})
+		stacks = append(stacks, res.stack)
+	}
+	registry.Unlock()
+
+	//符号化比较慢，放在锁外面
+	for i := range infos {
+		infos[i].Stack = formatStack(stacks[i])
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Bytes > infos[j].Bytes
+	})
+	return infos
+}
+
+// ResourceBytes 返回所有存活资源估算的显存总量
+func ResourceBytes() int64 {
+	registry.Lock()
+	defer registry.Unlock()
+	var total int64
+	for res := range registry.entries {
+		total += res.bytes
+	}
+	return total
+}
+
+// 按类型顺序重建所有存活的资源，Target 需要在它的纹理之后重建
+func restoreResources() {
+	registry.Lock()
+	entries := make([]*resource, 0, len(registry.entries))
+	for res := range registry.entries {
+		if res.restore != nil {
+			entries = append(entries, res)
+		}
+	}
+	registry.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].kind < entries[j].kind
+	})
+	for _, res := range entries {
+		res.restore()
+	}
+}
+
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// 纹理所有层级占用的字节数，layers 为数组层数或立方体的 6 个面
+func textureBytes(format TextureFormat, width, height, levels, layers int) int64 {
+	var total int64
+	for level := 0; level < levels; level++ {
+		total += int64(width) * int64(height) * int64(format.BytesPerPixel())
+		width, height = halfSize(width), halfSize(height)
+	}
+	return total * int64(layers)
+}
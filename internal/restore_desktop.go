@@ -0,0 +1,193 @@
+//go:build go1.24
+// +build go1.24
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"weak"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+/*
+ *	Restore
+ */
+
+// 登记表只保存资源的弱引用，不会阻止资源被回收
+func setRestore[T any](res *resource, obj *T, restore func(*T)) {
+	p := weak.Make(obj)
+	res.restore = func() {
+		if obj := p.Value(); obj != nil {
+			restore(obj)
+		}
+	}
+}
+
+// ContextLost 检查上下文是否因为 GPU 重置而丢失，需要驱动支持 GL_KHR_robustness，
+// 并且创建上下文时使用了 LOSE_CONTEXT_ON_RESET 策略；不支持时总是返回 false
+func ContextLost() bool {
+	c := theContext
+	if !c.lost && hasExtension("GL_KHR_robustness") {
+		c.lost = gl.GetGraphicsResetStatus() != gl.NO_ERROR
+	}
+	return c.lost
+}
+
+// RestoreContext 在新的上下文成为当前上下文之后调用（例如切换全屏时重新创建了窗口），重建所有存活的资源；
+// Shader、Sampler、Target 和 LoadTexture 载入的纹理会完整恢复，其他 Buffer 和 Texture 只恢复存储，内容需要由 SetRestoreFunc 重新上传
+func RestoreContext() error {
+	if err := gl.Init(); err != nil {
+		return err
+	}
+	c := theContext
+	c.lost = false
+	//占位纹理的内容不会恢复，需要时重新创建
+	c.placeholders = nil
+	c.caps = queryCaps()
+	c.resizeSlots()
+	c.queryViewport()
+	installDebugCallback()
+
+	restoreResources()
+	Invalidate()
+
+	return nil
+}
+
+// SetRestoreFunc 设置上下文恢复后重新上传纹理内容的函数，调用时纹理已经按原来的格式和大小重新分配
+func (tex *Texture) SetRestoreFunc(f func(tex *Texture)) {
+	tex.restoreFunc = f
+}
+
+// SetRestoreFunc 设置上下文恢复后重新上传缓冲区内容的函数，调用时缓冲区已经按原来的大小重新分配
+func (buffer *Buffer) SetRestoreFunc(f func(buffer *Buffer)) {
+	buffer.restoreFunc = f
+}
+
+func (buffer *Buffer) restore() {
+	gl.GenBuffers(1, &buffer.glid)
+	gl.BindVertexArray(0)
+	buffer.bind()
+	if buffer.size > 0 {
+		gl.BufferData(buffer.gltype, buffer.size, nil, buffer.drawType)
+	}
+	if label := buffer.Label(); label != "" {
+		buffer.SetLabel(label)
+	}
+
+	if buffer.restoreFunc != nil {
+		buffer.restoreFunc(buffer)
+	}
+	checkError()
+}
+
+func (tex *Texture) restore() {
+	gl.GenTextures(1, &tex.glid)
+	tex.samplerDirty = true
+
+	if tex.container != nil {
+		if err := tex.uploadContainer(tex.container); err != nil {
+			warnf("restore texture: %v", err)
+		}
+	} else if tex.width > 0 && tex.height > 0 {
+		tex.allocate()
+	}
+	if swizzle := tex.swizzle; swizzle != ([4]int32{}) {
+		tex.swizzle = [4]int32{}
+		tex.setSwizzle(swizzle[0], swizzle[1], swizzle[2], swizzle[3])
+	}
+	if label := tex.Label(); label != "" {
+		tex.SetLabel(label)
+	}
+
+	if tex.restoreFunc != nil {
+		tex.restoreFunc(tex)
+	}
+	checkError()
+}
+
+// 按当前的格式、大小和层级重新分配空的存储
+func (tex *Texture) allocate() {
+	internalFormat, format, xtype := textureFormat(tex.format)
+	tex.bind()
+
+	width, height := tex.width, tex.height
+	for level := 0; level < tex.levels; level++ {
+		switch tex.gltarget {
+		case gl.TEXTURE_2D_ARRAY:
+			gl.TexImage3D(tex.gltarget, int32(level), internalFormat, int32(width), int32(height), int32(tex.layers), 0,
+				format, xtype, nil)
+		case gl.TEXTURE_CUBE_MAP:
+			for face := CubePositiveX; face <= CubeNegativeZ; face++ {
+				gl.TexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(face), int32(level), internalFormat,
+					int32(width), int32(height), 0, format, xtype, nil)
+			}
+		default:
+			gl.TexImage2D(tex.gltarget, int32(level), internalFormat, int32(width), int32(height), 0, format, xtype, nil)
+		}
+		width, height = halfSize(width), halfSize(height)
+	}
+	gl.TexParameteri(tex.gltarget, gl.TEXTURE_MAX_LEVEL, int32(tex.levels-1))
+}
+
+func (sampler *Sampler) restore() {
+	gl.GenSamplers(1, &sampler.glid)
+	sampler.Update(sampler.options)
+}
+
+// 颜色和深度纹理已经先作为 Texture 恢复，这里重新创建 framebuffer 和 renderbuffer
+func (target *Target) restore() {
+	gl.GenFramebuffers(1, &target.glid)
+	target.colorBuffers = nil
+	target.depthBuffer = 0
+
+	target.bind()
+	theContext.dirtyFlag |= dirtyTarget
+	target.attachColors()
+	target.setDrawBuffers()
+	target.attachDepth()
+	target.checkStatus()
+	if label := target.Label(); label != "" {
+		target.SetLabel(label)
+	}
+	checkError()
+}
+
+// 重新链接 program，写回之前通过 SetUniform 设置的值
+func (shader *Shader) restore() {
+	values := make(map[string][]float32, len(shader.values))
+	for loc, v := range shader.values {
+		if name := shader.uniformName(loc); name != "" {
+			values[name] = v
+		}
+	}
+
+	program, err := linkProgram(shader.vertexSrc, shader.fragmentSrc, shader.attrs)
+	if err != nil {
+		warnf("restore shader: %v", err)
+		return
+	}
+	gl.GenVertexArrays(1, &shader.glvao)
+	shader.bufferDirty = true
+	shader.replaceProgram(program, activeAttributes(program), values)
+	if label := shader.Label(); label != "" {
+		gl.BindVertexArray(shader.glvao)
+		objectLabel(gl.VERTEX_ARRAY, shader.glvao, label)
+	}
+	checkError()
+}
+
+// 数组元素按连续的 location 处理
+func (shader *Shader) uniformName(loc int32) string {
+	for _, u := range shader.uniformList {
+		if loc == u.Location {
+			return u.Name
+		}
+		if u.Size > 1 && loc > u.Location && loc < u.Location+u.Size {
+			return fmt.Sprintf("%s[%d]", strings.TrimSuffix(u.Name, "[0]"), loc-u.Location)
+		}
+	}
+	return ""
+}
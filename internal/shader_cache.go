@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// 缓存键包含驱动信息、源码（ShaderLibrary 的宏已经注入源码）和属性绑定位置
+func programCacheKey(driver, vertexSrc, fragmentSrc string, attrs Attrs) string {
+	h := sha256.New()
+	h.Write([]byte(driver))
+	h.Write([]byte{0})
+	h.Write([]byte(vertexSrc))
+	h.Write([]byte{0})
+	h.Write([]byte(fragmentSrc))
+	for _, attr := range attrs {
+		h.Write([]byte{0})
+		h.Write([]byte(attr.Name))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func programCachePath(cacheDir, key string) string {
+	return filepath.Join(cacheDir, key+".bin")
+}
+
+// 文件格式：4 字节小端 binaryFormat + program 二进制
+func readProgramCache(cacheDir, key string) (uint32, []byte, error) {
+	data, err := os.ReadFile(programCachePath(cacheDir, key))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 4 {
+		return 0, nil, errors.New("program cache: truncated file")
+	}
+	return binary.LittleEndian.Uint32(data), data[4:], nil
+}
+
+func writeProgramCache(cacheDir, key string, format uint32, program []byte) error {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+
+	data := make([]byte, 4+len(program))
+	binary.LittleEndian.PutUint32(data, format)
+	copy(data[4:], program)
+
+	//先写临时文件再重命名，避免读到写了一半的缓存
+	tmp, err := os.CreateTemp(cacheDir, key+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), programCachePath(cacheDir, key))
+}
+
+func removeProgramCache(cacheDir, key string) {
+	os.Remove(programCachePath(cacheDir, key))
+}
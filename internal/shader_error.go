@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ShaderDiagnostic 是驱动编译日志中的一条信息，Line 为 0 表示日志里没有行号
+type ShaderDiagnostic struct {
+	Line     int
+	Severity string
+	Message  string
+}
+
+// ShaderCompileError 编译失败时返回，Error() 会带上出错行附近的源码
+type ShaderCompileError struct {
+	Stage       string
+	Source      string
+	Log         string
+	Diagnostics []ShaderDiagnostic
+}
+
+const diagnosticContext = 2
+
+var (
+	// NVIDIA: 0(12) : error C1008: undefined variable "foo"
+	nvidiaLogPattern = regexp.MustCompile(`^\s*\d+\((\d+)\)\s*:\s*(fatal error|error|warning)\s*(?:[A-Z]\d+)?\s*:\s*(.*)$`)
+	// Mesa: 0:12(5): error: `foo' undeclared
+	mesaLogPattern = regexp.MustCompile(`^\s*\d+:(\d+)\(\d+\)\s*:\s*(preprocessor error|error|warning)\s*:\s*(.*)$`)
+	// AMD、Intel、Apple: ERROR: 0:12: 'foo' : undeclared identifier
+	amdLogPattern = regexp.MustCompile(`^\s*(ERROR|WARNING)\s*:\s*\d+:(\d+)\s*:\s*(.*)$`)
+)
+
+func newShaderCompileError(stage, source, log string) *ShaderCompileError {
+	log = strings.TrimRight(log, "\x00")
+	return &ShaderCompileError{
+		Stage:       stage,
+		Source:      source,
+		Log:         log,
+		Diagnostics: parseInfoLog(log),
+	}
+}
+
+func parseInfoLog(log string) []ShaderDiagnostic {
+	var diagnostics []ShaderDiagnostic
+	for _, text := range strings.Split(log, "\n") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		var d ShaderDiagnostic
+		if m := nvidiaLogPattern.FindStringSubmatch(text); m != nil {
+			d.Line, _ = strconv.Atoi(m[1])
+			d.Severity, d.Message = m[2], m[3]
+		} else if m := mesaLogPattern.FindStringSubmatch(text); m != nil {
+			d.Line, _ = strconv.Atoi(m[1])
+			d.Severity, d.Message = m[2], m[3]
+		} else if m := amdLogPattern.FindStringSubmatch(text); m != nil {
+			d.Line, _ = strconv.Atoi(m[2])
+			d.Severity, d.Message = m[1], m[3]
+		} else {
+			d.Message = text
+		}
+		d.Severity = strings.ToLower(d.Severity)
+		if d.Severity == "fatal error" || d.Severity == "preprocessor error" {
+			d.Severity = "error"
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics
+}
+
+// 驱动报告的是经过 #line 调整后的行号，这里算出每一行源码对应的行号
+func sourceLineNumbers(lines []string) []int {
+	numbers := make([]int, len(lines))
+	line := 1
+	for i, text := range lines {
+		numbers[i] = line
+		line++
+		fields := strings.Fields(text)
+		if len(fields) >= 2 && fields[0] == "#line" {
+			if n, err := strconv.Atoi(fields[1]); err == nil {
+				line = n
+			}
+		}
+	}
+	return numbers
+}
+
+// 被后面的 #line 覆盖了行号的行（例如 ShaderLibrary 插入的 #define）和 #line 本身不是用户写的源码，不显示
+func hiddenLines(lines []string, numbers []int) []bool {
+	hidden := make([]bool, len(lines))
+	last := make(map[int]int, len(numbers))
+	for i, n := range numbers {
+		last[n] = i
+	}
+	for i, text := range lines {
+		fields := strings.Fields(text)
+		hidden[i] = last[numbers[i]] != i || len(fields) > 0 && fields[0] == "#line"
+	}
+	return hidden
+}
+
+func (e *ShaderCompileError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "failed to compile %s shader:", e.Stage)
+
+	lines := strings.Split(e.Source, "\n")
+	numbers := sourceLineNumbers(lines)
+	hidden := hiddenLines(lines, numbers)
+	for _, d := range e.Diagnostics {
+		b.WriteByte('\n')
+		if d.Line == 0 {
+			b.WriteString(d.Message)
+			continue
+		}
+		fmt.Fprintf(&b, "line %d: %s: %s\n", d.Line, d.Severity, d.Message)
+
+		//#line 之后的行号会重复，取最后一次出现的位置
+		i := -1
+		for j, n := range numbers {
+			if n == d.Line {
+				i = j
+			}
+		}
+		if i < 0 {
+			continue
+		}
+		start, end := i, i
+		for n := 0; n < diagnosticContext && start > 0; {
+			start--
+			if !hidden[start] {
+				n++
+			}
+		}
+		for n := 0; n < diagnosticContext && end < len(lines)-1; {
+			end++
+			if !hidden[end] {
+				n++
+			}
+		}
+		for j := start; j <= end; j++ {
+			if hidden[j] {
+				continue
+			}
+			mark := "  "
+			if j == i {
+				mark = "> "
+			}
+			fmt.Fprintf(&b, "%s%5d | %s\n", mark, numbers[j], strings.TrimRight(lines[j], "\r"))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
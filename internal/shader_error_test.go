@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseInfoLog(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want ShaderDiagnostic
+	}{
+		{"nvidia error", `0(12) : error C1008: undefined variable "foo"`,
+			ShaderDiagnostic{12, "error", `undefined variable "foo"`}},
+		{"nvidia warning", `0(3) : warning C7533: global variable gl_FragColor is deprecated after version 120`,
+			ShaderDiagnostic{3, "warning", "global variable gl_FragColor is deprecated after version 120"}},
+		{"nvidia fatal", `0(20) : fatal error C9999: *** exception during compilation ***`,
+			ShaderDiagnostic{20, "error", "*** exception during compilation ***"}},
+		{"mesa error", "0:12(5): error: `foo' undeclared",
+			ShaderDiagnostic{12, "error", "`foo' undeclared"}},
+		{"mesa preprocessor", `0:7(1): preprocessor error: syntax error, unexpected HASH_TOKEN`,
+			ShaderDiagnostic{7, "error", "syntax error, unexpected HASH_TOKEN"}},
+		{"mesa warning", `0:9(16): warning: ` + "`uv'" + ` used uninitialized`,
+			ShaderDiagnostic{9, "warning", "`uv' used uninitialized"}},
+		{"amd error", `ERROR: 0:12: 'foo' : undeclared identifier`,
+			ShaderDiagnostic{12, "error", "'foo' : undeclared identifier"}},
+		{"amd warning", `WARNING: 0:5: 'texture2D' : function is deprecated`,
+			ShaderDiagnostic{5, "warning", "'texture2D' : function is deprecated"}},
+		{"amd summary", `ERROR: 1 compilation errors.  No code generated.`,
+			ShaderDiagnostic{0, "", "ERROR: 1 compilation errors.  No code generated."}},
+	}
+	for _, test := range tests {
+		//驱动返回的日志以 NUL 结尾
+		got := newShaderCompileError("fragment", "", test.log+"\n\x00").Diagnostics
+		if len(got) != 1 || got[0] != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestShaderErrorLineAfterDefines(t *testing.T) {
+	source := strings.Join([]string{
+		"#version 330 core",
+		"in vec2 vUV;",
+		"out vec4 color;",
+		"void main() {",
+		"    color = foo;",
+		"}",
+	}, "\n")
+	injected := injectDefines(source, []string{"TINT", "ALPHA_TEST=1"})
+
+	//驱动按 #line 调整后的行号报告，与原始源码一致
+	err := newShaderCompileError("fragment", injected, "0:5(13): error: `foo' undeclared\n")
+	msg := err.Error()
+	if !strings.Contains(msg, "line 5: error: `foo' undeclared") {
+		t.Errorf("missing diagnostic:\n%s", msg)
+	}
+	if !strings.Contains(msg, ">     5 |     color = foo;") {
+		t.Errorf("wrong line marked:\n%s", msg)
+	}
+	if !strings.Contains(msg, "      3 | out vec4 color;") || !strings.Contains(msg, "      6 | }") {
+		t.Errorf("missing context lines:\n%s", msg)
+	}
+	if strings.Contains(msg, "#define") || strings.Contains(msg, "#line") {
+		t.Errorf("injected lines shown:\n%s", msg)
+	}
+}
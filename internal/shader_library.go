@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ShaderLibrary 按特性宏组合延迟编译着色器变体，相同组合返回同一个 *Shader
+type ShaderLibrary struct {
+	vertexSrc   string
+	fragmentSrc string
+	attrs       Attrs
+	variants    map[string]*Shader
+	failed      map[string]error //编译失败的组合不再重复编译
+}
+
+func NewShaderLibrary(vertexSrc, fragmentSrc string, attrs Attrs) *ShaderLibrary {
+	return &ShaderLibrary{
+		vertexSrc:   vertexSrc,
+		fragmentSrc: fragmentSrc,
+		attrs:       attrs,
+		variants:    make(map[string]*Shader),
+		failed:      make(map[string]error),
+	}
+}
+
+// Get 返回 defines 对应的变体，例如 Get("TINT", "ALPHA_TEST")，"NAME=VALUE" 形式会带上宏的值；
+// 变体通常在渲染循环中第一次用到时才编译，失败时返回错误（编译错误为 *ShaderCompileError），调用者可以换用其他变体，
+// 失败的组合会记住错误，之后直接返回
+func (lib *ShaderLibrary) Get(defines ...string) (*Shader, error) {
+	defines = normalizeDefines(defines)
+	key := defineKey(defines)
+	if shader, exist := lib.variants[key]; exist {
+		return shader, nil
+	}
+	if err, exist := lib.failed[key]; exist {
+		return nil, err
+	}
+
+	vertexSrc := injectDefines(lib.vertexSrc, defines)
+	fragmentSrc := injectDefines(lib.fragmentSrc, defines)
+	shader, err := newShader(vertexSrc, fragmentSrc, lib.attrs)
+	if err != nil {
+		lib.failed[key] = err
+		return nil, err
+	}
+	lib.variants[key] = shader
+
+	return shader, nil
+}
+
+func (lib *ShaderLibrary) Len() int {
+	return len(lib.variants)
+}
+
+// 去重并排序，保证宏的顺序不影响缓存命中
+func normalizeDefines(defines []string) []string {
+	if len(defines) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(defines))
+	result := make([]string, 0, len(defines))
+	for _, define := range defines {
+		define = strings.TrimSpace(define)
+		if define == "" || set[define] {
+			continue
+		}
+		set[define] = true
+		result = append(result, define)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// 每个宏前加上长度，宏里出现任何分隔符都不会让不同的组合得到相同的 key
+func defineKey(defines []string) string {
+	var b strings.Builder
+	for _, define := range defines {
+		b.WriteString(strconv.Itoa(len(define)))
+		b.WriteByte(':')
+		b.WriteString(define)
+	}
+	return b.String()
+}
+
+// #define 必须放在 #version 之后，插入后用 #line 恢复原来的行号
+func injectDefines(source string, defines []string) string {
+	if len(defines) == 0 {
+		return source
+	}
+
+	lines := strings.Split(source, "\n")
+	insert := 0
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#version") {
+			insert = i + 1
+			break
+		}
+	}
+
+	var b strings.Builder
+	for _, line := range lines[:insert] {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	for _, define := range defines {
+		b.WriteString("#define ")
+		if i := strings.IndexByte(define, '='); i >= 0 {
+			b.WriteString(define[:i])
+			b.WriteByte(' ')
+			b.WriteString(define[i+1:])
+		} else {
+			b.WriteString(define)
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("#line ")
+	b.WriteString(strconv.Itoa(insert + 1))
+	b.WriteByte('\n')
+	b.WriteString(strings.Join(lines[insert:], "\n"))
+
+	return b.String()
+}
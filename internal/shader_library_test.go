@@ -0,0 +1,17 @@
+package internal
+
+import "testing"
+
+func TestDefineKey(t *testing.T) {
+	if defineKey([]string{"A,B"}) == defineKey([]string{"A", "B"}) {
+		t.Error(`{"A,B"} and {"A", "B"} have the same key`)
+	}
+	if defineKey([]string{"A1:B"}) == defineKey([]string{"A", "B"}) {
+		t.Error(`{"A1:B"} and {"A", "B"} have the same key`)
+	}
+	a := defineKey(normalizeDefines([]string{"B", " A", "B"}))
+	b := defineKey(normalizeDefines([]string{"A", "B"}))
+	if a != b {
+		t.Errorf("order and duplicates changed the key: %q != %q", a, b)
+	}
+}
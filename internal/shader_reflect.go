@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ShaderAttribute 是链接后 program 中的活动顶点属性，Type 为 GL 类型（如 gl.FLOAT_VEC3）
+type ShaderAttribute struct {
+	Name       string
+	Location   int32
+	Type       uint32
+	Size       int32
+	Components int
+}
+
+// ShaderUniform 是链接后 program 中的活动 uniform，数组的 Name 带 "[0]" 后缀
+type ShaderUniform struct {
+	Name     string
+	Location int32
+	Type     uint32
+	Size     int32
+}
+
+var (
+	commentPattern = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*`)
+	// in vec3 a, b[2]; 捕获类型之后到分号之间的名字列表
+	attributePattern = regexp.MustCompile(`(?m)^\s*(?:layout\s*\([^)]*\)\s*)?(?:in|attribute)\s+` +
+		`(?:(?:highp|mediump|lowp|flat|smooth|noperspective)\s+)*\w+\s+([^;{}()]+);`)
+)
+
+// 源码中声明的属性，用来区分没有声明和被编译器优化掉的属性；注释中的声明不算
+func declaredAttributes(vertexSrc string) map[string]bool {
+	src := commentPattern.ReplaceAllString(vertexSrc, " ")
+	names := make(map[string]bool)
+	for _, m := range attributePattern.FindAllStringSubmatch(src, -1) {
+		for _, name := range strings.Split(m[1], ",") {
+			if i := strings.IndexByte(name, '['); i >= 0 {
+				name = name[:i]
+			}
+			if name = strings.TrimSpace(name); name != "" {
+				names[name] = true
+			}
+		}
+	}
+	return names
+}
+
+// 对照 Attrs 和着色器中的活动属性：
+// 属性不存在或分量数多于 GLSL 类型时返回错误；
+// 属性被优化掉、分量数少于 GLSL 类型（缺少的分量按 0,0,0,1 补齐）或着色器用到了 Attrs 中没有的属性时只打印警告
+func validateAttrs(vertexSrc string, attrs Attrs, active []ShaderAttribute) error {
+	var errs []string
+
+	inSource := declaredAttributes(vertexSrc)
+	declared := make(map[string]bool, len(attrs))
+	for _, attr := range attrs {
+		declared[attr.Name] = true
+
+		var found *ShaderAttribute
+		for i := range active {
+			if active[i].Name == attr.Name {
+				found = &active[i]
+				break
+			}
+		}
+
+		switch {
+		case found == nil && inSource[attr.Name]:
+			warnf("attribute %q is declared but not active, it may have been optimized out", attr.Name)
+		case found == nil:
+			errs = append(errs, fmt.Sprintf("attribute %q is not declared in the vertex shader", attr.Name))
+		case found.Components == 0:
+		case attr.Num > found.Components:
+			errs = append(errs, fmt.Sprintf("attribute %q has %d components, but the shader declares %d",
+				attr.Name, attr.Num, found.Components))
+		case attr.Num < found.Components:
+			warnf("attribute %q has %d components, the shader declares %d", attr.Name, attr.Num, found.Components)
+		}
+	}
+
+	for _, a := range active {
+		if !declared[a.Name] && !strings.HasPrefix(a.Name, "gl_") {
+			warnf("attribute %q is used by the shader but missing from Attrs", a.Name)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid vertex attributes: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
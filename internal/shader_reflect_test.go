@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDeclaredAttributes(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"simple", "in vec2 aPosition;\nin vec2 aUV;", []string{"aPosition", "aUV"}},
+		{"legacy", "attribute highp vec4 aColor;", []string{"aColor"}},
+		{"layout", "layout(location = 0) in vec3 aNormal;", []string{"aNormal"}},
+		{"list", "in vec3 a, b;\nin float c[2], d;", []string{"a", "b", "c", "d"}},
+		{"multi line", "in vec2\n    aOffset;", []string{"aOffset"}},
+		{"line comment", "// in vec2 aOld;\nin vec2 aNew; // in vec2 aTrailing;", []string{"aNew"}},
+		{"block comment", "/*\nin vec2 aOld;\n*/\nin vec2 aNew;", []string{"aNew"}},
+		{"not an attribute", "out vec2 vUV;\nuniform mat3 uProjection;\nvoid f(in vec2 p) {}", nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for name := range declaredAttributes(test.src) {
+			got = append(got, name)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
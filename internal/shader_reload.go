@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// ShaderWatcher 轮询着色器文件，文件修改后在渲染线程里重新编译
+type ShaderWatcher struct {
+	interval time.Duration
+	lastPoll time.Time
+	watches  []*shaderWatch
+}
+
+type shaderWatch struct {
+	shader       *Shader
+	vertexFile   string
+	fragmentFile string
+	vertexMod    time.Time
+	fragmentMod  time.Time
+}
+
+func NewShaderWatcher(interval time.Duration) *ShaderWatcher {
+	return &ShaderWatcher{interval: interval}
+}
+
+func (w *ShaderWatcher) Load(vertexFile, fragmentFile string, attrs Attrs) (*Shader, error) {
+	shader, err := LoadShader(vertexFile, fragmentFile, attrs)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Watch(shader, vertexFile, fragmentFile); err != nil {
+		return nil, err
+	}
+	return shader, nil
+}
+
+func (w *ShaderWatcher) Watch(shader *Shader, vertexFile, fragmentFile string) error {
+	watch := &shaderWatch{
+		shader:       shader,
+		vertexFile:   vertexFile,
+		fragmentFile: fragmentFile,
+	}
+	var err error
+	if watch.vertexMod, err = modTime(vertexFile); err != nil {
+		return err
+	}
+	if watch.fragmentMod, err = modTime(fragmentFile); err != nil {
+		return err
+	}
+	w.watches = append(w.watches, watch)
+	return nil
+}
+
+func (w *ShaderWatcher) Unwatch(shader *Shader) {
+	for i, watch := range w.watches {
+		if watch.shader == shader {
+			w.watches = append(w.watches[:i], w.watches[i+1:]...)
+			return
+		}
+	}
+}
+
+// Poll 每帧在渲染线程调用，间隔未到时直接返回；
+// 重新编译失败时保留旧的 program 并返回错误，文件再次修改后会重试
+func (w *ShaderWatcher) Poll() error {
+	now := time.Now()
+	if now.Sub(w.lastPoll) < w.interval {
+		return nil
+	}
+	w.lastPoll = now
+
+	var firstErr error
+	for _, watch := range w.watches {
+		if err := watch.poll(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (watch *shaderWatch) poll() error {
+	vertexMod, err := modTime(watch.vertexFile)
+	if err != nil {
+		return err
+	}
+	fragmentMod, err := modTime(watch.fragmentFile)
+	if err != nil {
+		return err
+	}
+	if vertexMod.Equal(watch.vertexMod) && fragmentMod.Equal(watch.fragmentMod) {
+		return nil
+	}
+	watch.vertexMod = vertexMod
+	watch.fragmentMod = fragmentMod
+
+	vertexSrc, err := os.ReadFile(watch.vertexFile)
+	if err != nil {
+		return err
+	}
+	fragmentSrc, err := os.ReadFile(watch.fragmentFile)
+	if err != nil {
+		return err
+	}
+	if err := watch.shader.Reload(string(vertexSrc), string(fragmentSrc)); err != nil {
+		return fmt.Errorf("reload %s, %s: %v", watch.vertexFile, watch.fragmentFile, err)
+	}
+	return nil
+}
+
+func modTime(file string) (time.Time, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
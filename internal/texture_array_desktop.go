@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+/*
+ *	TextureArray
+ */
+
+// TextureArray 是 GL_TEXTURE_2D_ARRAY，着色器中使用 sampler2DArray
+type TextureArray struct {
+	*Texture
+}
+
+func NewTextureArray(format TextureFormat, width, height, layers int) *TextureArray {
+	arr := &TextureArray{
+		Texture: newTexture(gl.TEXTURE_2D_ARRAY, format),
+	}
+	arr.width = width
+	arr.height = height
+	arr.levels = 1
+	arr.layers = layers
+
+	internalFormat, glformat, xtype := textureFormat(format)
+	arr.bind()
+	gl.TexImage3D(gl.TEXTURE_2D_ARRAY, 0, internalFormat, int32(width), int32(height), int32(layers), 0,
+		glformat, xtype, nil)
+	arr.updateBytes()
+	checkError()
+
+	return arr
+}
+
+func (arr *TextureArray) Layers() int {
+	return arr.layers
+}
+
+// UploadLayer 上传一整层，像素按纹理格式排列
+func (arr *TextureArray) UploadLayer(layer int, pixels []uint8) {
+	arr.SubUploadLayer(layer, pixels, 0, 0, arr.width, arr.height)
+}
+
+func (arr *TextureArray) SubUploadLayer(layer int, pixels []uint8, x, y, width, height int) {
+	if layer < 0 || layer >= arr.layers {
+		panic(errors.New("texture array: layer out of range"))
+	}
+	checkPixels(len(pixels), width, height, arr.format.BytesPerPixel())
+	_, format, xtype := textureFormat(arr.format)
+	arr.bind()
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexSubImage3D(gl.TEXTURE_2D_ARRAY, 0, int32(x), int32(y), int32(layer), int32(width), int32(height), 1,
+		format, xtype, gl.Ptr(pixels))
+	arr.generateMipmap()
+	checkError()
+}
+
+func (arr *TextureArray) UploadLayerImage(layer int, img image.Image) {
+	rgba := imageToRGBA(img, arr.format)
+	size := rgba.Rect.Size()
+	if size.X > arr.width || size.Y > arr.height {
+		panic(errors.New("texture array: image larger than layer"))
+	}
+	arr.SubUploadLayer(layer, rgba.Pix, 0, 0, size.X, size.Y)
+}
+
+/*
+ *	CubeTexture
+ */
+type CubeFace int
+
+const (
+	CubePositiveX CubeFace = iota
+	CubeNegativeX
+	CubePositiveY
+	CubeNegativeY
+	CubePositiveZ
+	CubeNegativeZ
+)
+
+// CubeTexture 是 GL_TEXTURE_CUBE_MAP，着色器中使用 samplerCube
+type CubeTexture struct {
+	*Texture
+}
+
+func NewCubeTexture(format TextureFormat, size int) *CubeTexture {
+	cube := &CubeTexture{
+		Texture: newTexture(gl.TEXTURE_CUBE_MAP, format),
+	}
+	cube.width = size
+	cube.height = size
+	cube.levels = 1
+	cube.layers = 6
+
+	internalFormat, glformat, xtype := textureFormat(format)
+	cube.bind()
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	for face := CubePositiveX; face <= CubeNegativeZ; face++ {
+		gl.TexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(face), 0, internalFormat, int32(size), int32(size), 0,
+			glformat, xtype, nil)
+	}
+	cube.updateBytes()
+	checkError()
+
+	return cube
+}
+
+func (cube *CubeTexture) UploadFace(face CubeFace, pixels []uint8) {
+	if face < CubePositiveX || face > CubeNegativeZ {
+		panic(errors.New("cube texture: invalid face"))
+	}
+	checkPixels(len(pixels), cube.width, cube.height, cube.format.BytesPerPixel())
+	_, format, xtype := textureFormat(cube.format)
+	cube.bind()
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexSubImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(face), 0, 0, 0, int32(cube.width), int32(cube.height),
+		format, xtype, gl.Ptr(pixels))
+	cube.generateMipmap()
+	checkError()
+}
+
+func (cube *CubeTexture) UploadFaceImage(face CubeFace, img image.Image) {
+	rgba := imageToRGBA(img, cube.format)
+	if rgba.Rect.Dx() != cube.width || rgba.Rect.Dy() != cube.height {
+		panic(errors.New("cube texture: image size does not match face size"))
+	}
+	cube.UploadFace(face, rgba.Pix)
+}
+
+// 按层上传只支持 8 位 RGBA 格式的图片
+func imageToRGBA(img image.Image, format TextureFormat) *image.RGBA {
+	if format != FormatRGBA8 && format != FormatSRGB8Alpha8 {
+		panic(errors.New("upload image: texture format is not RGBA8"))
+	}
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Stride == rgba.Rect.Dx()*4 {
+		return rgba
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// 驱动不支持压缩格式时在 CPU 上解压成 RGBA（非预乘），目前支持 BC1、BC2、BC3
+func decompressLevel(format uint32, data []byte, width, height int) ([]byte, error) {
+	var blockBytes int
+	var decode func(block []byte, out *[16][4]byte)
+	switch format {
+	case compressedRGBAS3TCDXT1, compressedSRGBAlphaS3TCDXT1:
+		blockBytes = 8
+		decode = func(block []byte, out *[16][4]byte) { decodeBC1(block, out, true) }
+	case compressedRGBS3TCDXT1, compressedSRGBS3TCDXT1:
+		//不带 alpha 的 DXT1，第 4 个颜色是不透明的黑色
+		blockBytes = 8
+		decode = func(block []byte, out *[16][4]byte) {
+			decodeBC1(block, out, true)
+			for i := range out {
+				out[i][3] = 255
+			}
+		}
+	case compressedRGBAS3TCDXT3, compressedSRGBAlphaS3TCDXT3:
+		blockBytes = 16
+		decode = decodeBC2
+	case compressedRGBAS3TCDXT5, compressedSRGBAlphaS3TCDXT5:
+		blockBytes = 16
+		decode = decodeBC3
+	default:
+		return nil, fmt.Errorf("no CPU decoder for compressed format 0x%X", format)
+	}
+
+	blocksX, blocksY := (width+3)/4, (height+3)/4
+	if len(data) < blocksX*blocksY*blockBytes {
+		return nil, fmt.Errorf("compressed data too short")
+	}
+
+	pix := make([]byte, width*height*4)
+	var out [16][4]byte
+	for by := 0; by < blocksY; by++ {
+		for bx := 0; bx < blocksX; bx++ {
+			offset := (by*blocksX + bx) * blockBytes
+			decode(data[offset:offset+blockBytes], &out)
+
+			for i, c := range out {
+				x, y := bx*4+i%4, by*4+i/4
+				if x >= width || y >= height {
+					continue
+				}
+				copy(pix[(y*width+x)*4:], c[:])
+			}
+		}
+	}
+	return pix, nil
+}
+
+func rgb565(c uint16) [4]byte {
+	r := byte(c >> 11 & 0x1F)
+	g := byte(c >> 5 & 0x3F)
+	b := byte(c & 0x1F)
+	return [4]byte{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 255}
+}
+
+func mixColor(c0, c1 [4]byte, w0, w1, div int) [4]byte {
+	var c [4]byte
+	for i := 0; i < 3; i++ {
+		c[i] = byte((int(c0[i])*w0 + int(c1[i])*w1) / div)
+	}
+	c[3] = 255
+	return c
+}
+
+// alpha1 为 true 时按 BC1 规则在 c0 <= c1 时使用 3 色 + 透明模式
+func decodeBC1(block []byte, out *[16][4]byte, alpha1 bool) {
+	c0 := binary.LittleEndian.Uint16(block[0:])
+	c1 := binary.LittleEndian.Uint16(block[2:])
+	indices := binary.LittleEndian.Uint32(block[4:])
+
+	var palette [4][4]byte
+	palette[0] = rgb565(c0)
+	palette[1] = rgb565(c1)
+	if c0 > c1 || !alpha1 {
+		palette[2] = mixColor(palette[0], palette[1], 2, 1, 3)
+		palette[3] = mixColor(palette[0], palette[1], 1, 2, 3)
+	} else {
+		palette[2] = mixColor(palette[0], palette[1], 1, 1, 2)
+		palette[3] = [4]byte{0, 0, 0, 0}
+	}
+
+	for i := 0; i < 16; i++ {
+		out[i] = palette[indices>>(uint(i)*2)&3]
+	}
+}
+
+func decodeBC2(block []byte, out *[16][4]byte) {
+	decodeBC1(block[8:], out, false)
+	alpha := binary.LittleEndian.Uint64(block[0:])
+	for i := 0; i < 16; i++ {
+		a := byte(alpha >> (uint(i) * 4) & 0xF)
+		out[i][3] = a<<4 | a
+	}
+}
+
+func decodeBC3(block []byte, out *[16][4]byte) {
+	decodeBC1(block[8:], out, false)
+
+	a0, a1 := int(block[0]), int(block[1])
+	var palette [8]byte
+	palette[0], palette[1] = byte(a0), byte(a1)
+	if a0 > a1 {
+		for i := 2; i < 8; i++ {
+			palette[i] = byte(((8-i)*a0 + (i-1)*a1) / 7)
+		}
+	} else {
+		for i := 2; i < 6; i++ {
+			palette[i] = byte(((6-i)*a0 + (i-1)*a1) / 5)
+		}
+		palette[6], palette[7] = 0, 255
+	}
+
+	var bits uint64
+	for i := 0; i < 6; i++ {
+		bits |= uint64(block[2+i]) << (uint(i) * 8)
+	}
+	for i := 0; i < 16; i++ {
+		out[i][3] = palette[bits>>(uint(i)*3)&7]
+	}
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestDecodeBC1(t *testing.T) {
+	tests := []struct {
+		name  string
+		block []byte
+		want  [4][4]byte
+	}{
+		{
+			//c0 > c1：四色模式，红色到蓝色
+			name:  "four colors",
+			block: []byte{0x00, 0xF8, 0x1F, 0x00, 0xE4, 0, 0, 0},
+			want:  [4][4]byte{{255, 0, 0, 255}, {0, 0, 255, 255}, {170, 0, 85, 255}, {85, 0, 170, 255}},
+		},
+		{
+			//c0 <= c1：三色加透明
+			name:  "three colors and transparent",
+			block: []byte{0x1F, 0x00, 0x00, 0xF8, 0xE4, 0, 0, 0},
+			want:  [4][4]byte{{0, 0, 255, 255}, {255, 0, 0, 255}, {127, 0, 127, 255}, {0, 0, 0, 0}},
+		},
+	}
+	for _, test := range tests {
+		var out [16][4]byte
+		decodeBC1(test.block, &out, true)
+		for i, want := range test.want {
+			if out[i] != want {
+				t.Errorf("%s: pixel %d = %v, want %v", test.name, i, out[i], want)
+			}
+		}
+		//其余像素的索引为 0
+		for i := 4; i < 16; i++ {
+			if out[i] != test.want[0] {
+				t.Errorf("%s: pixel %d = %v, want %v", test.name, i, out[i], test.want[0])
+			}
+		}
+	}
+}
+
+func TestDecodeBC3(t *testing.T) {
+	//像素 0..3 的 alpha 索引为 0、1、2、7
+	bits := uint64(0 | 1<<3 | 2<<6 | 7<<9)
+	alphaBlock := func(a0, a1 byte) []byte {
+		block := []byte{a0, a1, 0, 0, 0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0}
+		for i := 0; i < 6; i++ {
+			block[2+i] = byte(bits >> (uint(i) * 8))
+		}
+		return block
+	}
+
+	tests := []struct {
+		name  string
+		block []byte
+		want  [4]byte
+	}{
+		{"eight alphas", alphaBlock(255, 0), [4]byte{255, 0, 218, 36}},
+		{"six alphas", alphaBlock(0, 255), [4]byte{0, 255, 51, 255}},
+	}
+	for _, test := range tests {
+		var out [16][4]byte
+		decodeBC3(test.block, &out)
+		for i, want := range test.want {
+			if out[i] != [4]byte{255, 255, 255, want} {
+				t.Errorf("%s: pixel %d = %v, want alpha %d", test.name, i, out[i], want)
+			}
+		}
+	}
+}
+
+func TestDecompressLevel(t *testing.T) {
+	//2x2 的层只取块的左上角
+	block := []byte{0x00, 0xF8, 0x1F, 0x00, 0x04, 0x04, 0, 0}
+	pix, err := decompressLevel(compressedRGBAS3TCDXT1, block, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		255, 0, 0, 255, 0, 0, 255, 255,
+		255, 0, 0, 255, 0, 0, 255, 255,
+	}
+	if string(pix) != string(want) {
+		t.Errorf("pixels = %v, want %v", pix, want)
+	}
+
+	if _, err := decompressLevel(compressedRGBAS3TCDXT5, block, 4, 4); err == nil {
+		t.Error("short data did not return an error")
+	}
+	if _, err := decompressLevel(compressedRGBAASTC4x4, make([]byte, 16), 4, 4); err == nil {
+		t.Error("unsupported format did not return an error")
+	}
+}
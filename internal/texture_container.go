@@ -0,0 +1,575 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 压缩纹理格式，数值与 GL 枚举相同
+const (
+	compressedRGBS3TCDXT1        = 0x83F0 //GL_COMPRESSED_RGB_S3TC_DXT1_EXT
+	compressedRGBAS3TCDXT1       = 0x83F1 //GL_COMPRESSED_RGBA_S3TC_DXT1_EXT
+	compressedRGBAS3TCDXT3       = 0x83F2 //GL_COMPRESSED_RGBA_S3TC_DXT3_EXT
+	compressedRGBAS3TCDXT5       = 0x83F3 //GL_COMPRESSED_RGBA_S3TC_DXT5_EXT
+	compressedSRGBS3TCDXT1       = 0x8C4C //GL_COMPRESSED_SRGB_S3TC_DXT1_EXT
+	compressedSRGBAlphaS3TCDXT1  = 0x8C4D //GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT1_EXT
+	compressedSRGBAlphaS3TCDXT3  = 0x8C4E //GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT3_EXT
+	compressedSRGBAlphaS3TCDXT5  = 0x8C4F //GL_COMPRESSED_SRGB_ALPHA_S3TC_DXT5_EXT
+	compressedRedRGTC1           = 0x8DBB //GL_COMPRESSED_RED_RGTC1
+	compressedSignedRedRGTC1     = 0x8DBC //GL_COMPRESSED_SIGNED_RED_RGTC1
+	compressedRGRGTC2            = 0x8DBD //GL_COMPRESSED_RG_RGTC2
+	compressedSignedRGRGTC2      = 0x8DBE //GL_COMPRESSED_SIGNED_RG_RGTC2
+	compressedRGBABPTCUnorm      = 0x8E8C //GL_COMPRESSED_RGBA_BPTC_UNORM
+	compressedSRGBAlphaBPTCUnorm = 0x8E8D //GL_COMPRESSED_SRGB_ALPHA_BPTC_UNORM
+	compressedRGBBPTCSignedFloat = 0x8E8E //GL_COMPRESSED_RGB_BPTC_SIGNED_FLOAT
+	compressedRGBBPTCUnsigned    = 0x8E8F //GL_COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT
+	compressedETC1RGB8           = 0x8D64 //GL_ETC1_RGB8_OES
+	compressedR11EAC             = 0x9270 //GL_COMPRESSED_R11_EAC
+	compressedRG11EAC            = 0x9272 //GL_COMPRESSED_RG11_EAC
+	compressedRGB8ETC2           = 0x9274 //GL_COMPRESSED_RGB8_ETC2
+	compressedSRGB8ETC2          = 0x9275 //GL_COMPRESSED_SRGB8_ETC2
+	compressedRGB8A1ETC2         = 0x9276 //GL_COMPRESSED_RGB8_PUNCHTHROUGH_ALPHA1_ETC2
+	compressedSRGB8A1ETC2        = 0x9277 //GL_COMPRESSED_SRGB8_PUNCHTHROUGH_ALPHA1_ETC2
+	compressedRGBA8ETC2EAC       = 0x9278 //GL_COMPRESSED_RGBA8_ETC2_EAC
+	compressedSRGB8Alpha8ETC2EAC = 0x9279 //GL_COMPRESSED_SRGB8_ALPHA8_ETC2_EAC
+	compressedRGBAASTC4x4        = 0x93B0 //GL_COMPRESSED_RGBA_ASTC_4x4_KHR，之后依次为 5x4 ... 12x12
+	compressedSRGB8Alpha8ASTC4x4 = 0x93D0 //GL_COMPRESSED_SRGB8_ALPHA8_ASTC_4x4_KHR
+
+	glRGBA          = 0x1908 //gl.RGBA
+	glBGRA          = 0x80E1 //gl.BGRA
+	glRGBA8         = 0x8058 //gl.RGBA8
+	glSRGB8Alpha8   = 0x8C43 //gl.SRGB8_ALPHA8
+	glUnsignedByte  = 0x1401 //gl.UNSIGNED_BYTE
+	astcBlockFormat = 14
+)
+
+var astcBlockSizes = [astcBlockFormat][2]int{
+	{4, 4}, {5, 4}, {5, 5}, {6, 5}, {6, 6}, {8, 5}, {8, 6},
+	{8, 8}, {10, 5}, {10, 6}, {10, 8}, {10, 10}, {12, 10}, {12, 12},
+}
+
+// textureContainer 是 KTX、KTX2、DDS 解析后的结果，levels 从第 0 层开始
+type textureContainer struct {
+	width, height  int
+	internalFormat uint32
+	format, xtype  uint32 //非压缩格式使用
+	compressed     bool
+	premultiplied  bool //DDS 的 DXT2、DXT4 是预乘 alpha 的
+	levels         [][]byte
+}
+
+// 文件头中的大小超过这个值时认为文件已损坏
+const maxContainerSize = 1 << 16
+
+// 检查文件头中的宽高和层数，之后才能按这些值计算分配的大小
+func checkContainerSize(width, height, levels uint32) error {
+	if width == 0 || height == 0 || width > maxContainerSize || height > maxContainerSize {
+		return fmt.Errorf("invalid texture size %dx%d", width, height)
+	}
+	if levels > uint32(mipLevels(int(width), int(height))) {
+		return fmt.Errorf("%d mip levels is too many for %dx%d", levels, width, height)
+	}
+	return nil
+}
+
+// 读取 n 字节，n 来自文件头，先和剩余的数据比较，避免按损坏的大小分配内存
+func readBytes(r *bytes.Reader, n uint64) ([]byte, error) {
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data := make([]byte, n)
+	r.Read(data)
+	return data, nil
+}
+
+func skipBytes(r *bytes.Reader, n uint64) error {
+	if n > uint64(r.Len()) {
+		return io.ErrUnexpectedEOF
+	}
+	_, err := r.Seek(int64(n), io.SeekCurrent)
+	return err
+}
+
+// 压缩块的宽、高和字节数
+func compressedBlock(format uint32) (int, int, int, bool) {
+	switch {
+	case format >= compressedRGBAASTC4x4 && format < compressedRGBAASTC4x4+astcBlockFormat:
+		size := astcBlockSizes[format-compressedRGBAASTC4x4]
+		return size[0], size[1], 16, true
+	case format >= compressedSRGB8Alpha8ASTC4x4 && format < compressedSRGB8Alpha8ASTC4x4+astcBlockFormat:
+		size := astcBlockSizes[format-compressedSRGB8Alpha8ASTC4x4]
+		return size[0], size[1], 16, true
+	}
+
+	switch format {
+	case compressedRGBS3TCDXT1, compressedRGBAS3TCDXT1, compressedSRGBS3TCDXT1, compressedSRGBAlphaS3TCDXT1,
+		compressedRedRGTC1, compressedSignedRedRGTC1,
+		compressedETC1RGB8, compressedRGB8ETC2, compressedSRGB8ETC2, compressedRGB8A1ETC2, compressedSRGB8A1ETC2,
+		compressedR11EAC:
+		return 4, 4, 8, true
+	case compressedRGBAS3TCDXT3, compressedRGBAS3TCDXT5, compressedSRGBAlphaS3TCDXT3, compressedSRGBAlphaS3TCDXT5,
+		compressedRGRGTC2, compressedSignedRGRGTC2,
+		compressedRGBABPTCUnorm, compressedSRGBAlphaBPTCUnorm, compressedRGBBPTCSignedFloat, compressedRGBBPTCUnsigned,
+		compressedRGBA8ETC2EAC, compressedSRGB8Alpha8ETC2EAC, compressedRG11EAC:
+		return 4, 4, 16, true
+	default:
+		return 0, 0, 0, false
+	}
+}
+
+func (c *textureContainer) levelSize(level int) (int, int) {
+	width, height := c.width>>uint(level), c.height>>uint(level)
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	return width, height
+}
+
+// 每一层数据应有的字节数
+func (c *textureContainer) levelBytes(level int) int {
+	width, height := c.levelSize(level)
+	if c.compressed {
+		bw, bh, size, _ := compressedBlock(c.internalFormat)
+		return ((width + bw - 1) / bw) * ((height + bh - 1) / bh) * size
+	}
+	return width * height * 4
+}
+
+func (c *textureContainer) check() error {
+	if c.width <= 0 || c.height <= 0 {
+		return errors.New("invalid texture size")
+	}
+	if len(c.levels) == 0 {
+		return errors.New("no image data")
+	}
+	if c.compressed {
+		if _, _, _, ok := compressedBlock(c.internalFormat); !ok {
+			return fmt.Errorf("unsupported compressed format 0x%X", c.internalFormat)
+		}
+	}
+	for i, level := range c.levels {
+		if len(level) < c.levelBytes(i) {
+			return fmt.Errorf("mip level %d: truncated data", i)
+		}
+	}
+	return nil
+}
+
+/*
+ *	KTX
+ */
+var ktxIdentifier = []byte{0xAB, 'K', 'T', 'X', ' ', '1', '1', 0xBB, '\r', '\n', 0x1A, '\n'}
+
+type ktxHeader struct {
+	Endianness            uint32
+	GLType                uint32
+	GLTypeSize            uint32
+	GLFormat              uint32
+	GLInternalFormat      uint32
+	GLBaseInternalFormat  uint32
+	PixelWidth            uint32
+	PixelHeight           uint32
+	PixelDepth            uint32
+	NumberOfArrayElements uint32
+	NumberOfFaces         uint32
+	NumberOfMipmapLevels  uint32
+	BytesOfKeyValueData   uint32
+}
+
+func parseKTX(reader io.Reader) (*textureContainer, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, ktxIdentifier) {
+		return nil, errors.New("ktx: invalid identifier")
+	}
+	r := bytes.NewReader(data[len(ktxIdentifier):])
+
+	var order binary.ByteOrder = binary.LittleEndian
+	var header ktxHeader
+	if err := binary.Read(r, order, &header); err != nil {
+		return nil, err
+	}
+	if header.Endianness != 0x04030201 {
+		order = binary.BigEndian
+		h := header
+		//按大端重新解释已经读出的字段
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, &h)
+		binary.Read(&buf, order, &header)
+	}
+
+	if header.PixelDepth > 1 || header.NumberOfArrayElements > 0 || header.NumberOfFaces > 1 {
+		return nil, errors.New("ktx: only 2D textures are supported")
+	}
+	if err := checkContainerSize(header.PixelWidth, header.PixelHeight, header.NumberOfMipmapLevels); err != nil {
+		return nil, fmt.Errorf("ktx: %v", err)
+	}
+	if err := skipBytes(r, uint64(header.BytesOfKeyValueData)); err != nil {
+		return nil, err
+	}
+
+	c := &textureContainer{
+		width:          int(header.PixelWidth),
+		height:         int(header.PixelHeight),
+		internalFormat: header.GLInternalFormat,
+		format:         header.GLFormat,
+		xtype:          header.GLType,
+		compressed:     header.GLType == 0,
+	}
+	if !c.compressed && (header.GLFormat != glRGBA && header.GLFormat != glBGRA || header.GLType != glUnsignedByte) {
+		return nil, errors.New("ktx: only RGBA8 uncompressed data is supported")
+	}
+
+	levels := int(header.NumberOfMipmapLevels)
+	if levels == 0 {
+		levels = 1
+	}
+	for i := 0; i < levels; i++ {
+		var size uint32
+		if err := binary.Read(r, order, &size); err != nil {
+			return nil, fmt.Errorf("ktx: mip level %d: %v", i, err)
+		}
+		level, err := readBytes(r, uint64(size))
+		if err != nil {
+			return nil, fmt.Errorf("ktx: mip level %d: %v", i, err)
+		}
+		c.levels = append(c.levels, level)
+
+		//mipPadding
+		if pad := (4 - size%4) % 4; pad > 0 && i < levels-1 {
+			if err := skipBytes(r, uint64(pad)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := c.check(); err != nil {
+		return nil, fmt.Errorf("ktx: %v", err)
+	}
+	return c, nil
+}
+
+/*
+ *	KTX2
+ */
+var ktx2Identifier = []byte{0xAB, 'K', 'T', 'X', ' ', '2', '0', 0xBB, '\r', '\n', 0x1A, '\n'}
+
+type ktx2Header struct {
+	VkFormat               uint32
+	TypeSize               uint32
+	PixelWidth             uint32
+	PixelHeight            uint32
+	PixelDepth             uint32
+	LayerCount             uint32
+	FaceCount              uint32
+	LevelCount             uint32
+	SupercompressionScheme uint32
+	DFDByteOffset          uint32
+	DFDByteLength          uint32
+	KVDByteOffset          uint32
+	KVDByteLength          uint32
+	SGDByteOffset          uint64
+	SGDByteLength          uint64
+}
+
+type ktx2Level struct {
+	ByteOffset             uint64
+	ByteLength             uint64
+	UncompressedByteLength uint64
+}
+
+// VkFormat 对应的 GL 格式
+func vkFormat(format uint32) (uint32, bool, bool) {
+	const (
+		vkR8G8B8A8Unorm = 37
+		vkR8G8B8A8SRGB  = 43
+		vkBC1RGBUnorm   = 131
+		vkETC2RGB8Unorm = 147
+		vkASTC4x4Unorm  = 157
+	)
+	bc := []uint32{
+		compressedRGBS3TCDXT1, compressedSRGBS3TCDXT1, compressedRGBAS3TCDXT1, compressedSRGBAlphaS3TCDXT1,
+		compressedRGBAS3TCDXT3, compressedSRGBAlphaS3TCDXT3, compressedRGBAS3TCDXT5, compressedSRGBAlphaS3TCDXT5,
+		compressedRedRGTC1, compressedSignedRedRGTC1, compressedRGRGTC2, compressedSignedRGRGTC2,
+		compressedRGBBPTCUnsigned, compressedRGBBPTCSignedFloat, compressedRGBABPTCUnorm, compressedSRGBAlphaBPTCUnorm,
+	}
+	etc := []uint32{
+		compressedRGB8ETC2, compressedSRGB8ETC2, compressedRGB8A1ETC2, compressedSRGB8A1ETC2,
+		compressedRGBA8ETC2EAC, compressedSRGB8Alpha8ETC2EAC, compressedR11EAC, 0, compressedRG11EAC,
+	}
+
+	switch {
+	case format == vkR8G8B8A8Unorm:
+		return glRGBA8, false, true
+	case format == vkR8G8B8A8SRGB:
+		return glSRGB8Alpha8, false, true
+	case format >= vkBC1RGBUnorm && format < vkBC1RGBUnorm+uint32(len(bc)):
+		return bc[format-vkBC1RGBUnorm], true, true
+	case format >= vkETC2RGB8Unorm && format < vkETC2RGB8Unorm+uint32(len(etc)):
+		f := etc[format-vkETC2RGB8Unorm]
+		return f, true, f != 0
+	case format >= vkASTC4x4Unorm && format < vkASTC4x4Unorm+astcBlockFormat*2:
+		i := format - vkASTC4x4Unorm
+		if i%2 == 0 {
+			return compressedRGBAASTC4x4 + i/2, true, true
+		}
+		return compressedSRGB8Alpha8ASTC4x4 + i/2, true, true
+	default:
+		return 0, false, false
+	}
+}
+
+func parseKTX2(r io.Reader) (*textureContainer, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, ktx2Identifier) {
+		return nil, errors.New("ktx2: invalid identifier")
+	}
+
+	reader := bytes.NewReader(data[len(ktx2Identifier):])
+	var header ktx2Header
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.SupercompressionScheme != 0 {
+		return nil, fmt.Errorf("ktx2: unsupported supercompression scheme %d", header.SupercompressionScheme)
+	}
+	if header.PixelDepth > 1 || header.LayerCount > 1 || header.FaceCount > 1 {
+		return nil, errors.New("ktx2: only 2D textures are supported")
+	}
+
+	if err := checkContainerSize(header.PixelWidth, header.PixelHeight, header.LevelCount); err != nil {
+		return nil, fmt.Errorf("ktx2: %v", err)
+	}
+
+	format, compressed, ok := vkFormat(header.VkFormat)
+	if !ok {
+		return nil, fmt.Errorf("ktx2: unsupported vkFormat %d", header.VkFormat)
+	}
+
+	levelCount := int(header.LevelCount)
+	if levelCount == 0 {
+		levelCount = 1
+	}
+	index := make([]ktx2Level, levelCount)
+	if err := binary.Read(reader, binary.LittleEndian, index); err != nil {
+		return nil, err
+	}
+
+	c := &textureContainer{
+		width:          int(header.PixelWidth),
+		height:         int(header.PixelHeight),
+		internalFormat: format,
+		format:         glRGBA,
+		xtype:          glUnsignedByte,
+		compressed:     compressed,
+	}
+	for i, level := range index {
+		end := level.ByteOffset + level.ByteLength
+		if end > uint64(len(data)) || end < level.ByteOffset {
+			return nil, fmt.Errorf("ktx2: mip level %d out of range", i)
+		}
+		c.levels = append(c.levels, data[level.ByteOffset:end])
+	}
+
+	if err := c.check(); err != nil {
+		return nil, fmt.Errorf("ktx2: %v", err)
+	}
+	return c, nil
+}
+
+/*
+ *	DDS
+ */
+type ddsPixelFormat struct {
+	Size        uint32
+	Flags       uint32
+	FourCC      [4]byte
+	RGBBitCount uint32
+	RBitMask    uint32
+	GBitMask    uint32
+	BBitMask    uint32
+	ABitMask    uint32
+}
+
+type ddsHeader struct {
+	Size              uint32
+	Flags             uint32
+	Height            uint32
+	Width             uint32
+	PitchOrLinearSize uint32
+	Depth             uint32
+	MipMapCount       uint32
+	Reserved1         [11]uint32
+	PixelFormat       ddsPixelFormat
+	Caps              uint32
+	Caps2             uint32
+	Caps3             uint32
+	Caps4             uint32
+	Reserved2         uint32
+}
+
+type ddsHeaderDX10 struct {
+	DXGIFormat        uint32
+	ResourceDimension uint32
+	MiscFlag          uint32
+	ArraySize         uint32
+	MiscFlags2        uint32
+}
+
+const (
+	ddpfFourCC    = 0x4
+	ddpfRGB       = 0x40
+	ddsCaps2Cube  = 0x200
+	ddsCaps2Vol   = 0x200000
+	ddsMipMapFlag = 0x20000
+)
+
+func dxgiFormat(format uint32) (uint32, bool, bool) {
+	switch format {
+	case 28: //DXGI_FORMAT_R8G8B8A8_UNORM
+		return glRGBA8, false, true
+	case 29: //DXGI_FORMAT_R8G8B8A8_UNORM_SRGB
+		return glSRGB8Alpha8, false, true
+	case 71:
+		return compressedRGBAS3TCDXT1, true, true
+	case 72:
+		return compressedSRGBAlphaS3TCDXT1, true, true
+	case 74:
+		return compressedRGBAS3TCDXT3, true, true
+	case 75:
+		return compressedSRGBAlphaS3TCDXT3, true, true
+	case 77:
+		return compressedRGBAS3TCDXT5, true, true
+	case 78:
+		return compressedSRGBAlphaS3TCDXT5, true, true
+	case 80:
+		return compressedRedRGTC1, true, true
+	case 81:
+		return compressedSignedRedRGTC1, true, true
+	case 83:
+		return compressedRGRGTC2, true, true
+	case 84:
+		return compressedSignedRGRGTC2, true, true
+	case 95:
+		return compressedRGBBPTCUnsigned, true, true
+	case 96:
+		return compressedRGBBPTCSignedFloat, true, true
+	case 98:
+		return compressedRGBABPTCUnorm, true, true
+	case 99:
+		return compressedSRGBAlphaBPTCUnorm, true, true
+	default:
+		return 0, false, false
+	}
+}
+
+func parseDDS(reader io.Reader) (*textureContainer, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte("DDS ")) {
+		return nil, errors.New("dds: invalid magic")
+	}
+	r := bytes.NewReader(data[4:])
+
+	var header ddsHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Size != 124 || header.PixelFormat.Size != 32 {
+		return nil, errors.New("dds: invalid header")
+	}
+	if header.Caps2&(ddsCaps2Cube|ddsCaps2Vol) != 0 {
+		return nil, errors.New("dds: only 2D textures are supported")
+	}
+	levels := 1
+	if header.Flags&ddsMipMapFlag != 0 && header.MipMapCount > 1 {
+		levels = int(header.MipMapCount)
+	}
+	if err := checkContainerSize(header.Width, header.Height, uint32(levels)); err != nil {
+		return nil, fmt.Errorf("dds: %v", err)
+	}
+
+	c := &textureContainer{
+		width:  int(header.Width),
+		height: int(header.Height),
+		format: glRGBA,
+		xtype:  glUnsignedByte,
+	}
+
+	pf := header.PixelFormat
+	switch {
+	case pf.Flags&ddpfFourCC != 0:
+		ok := true
+		switch string(pf.FourCC[:]) {
+		case "DXT1":
+			c.internalFormat = compressedRGBAS3TCDXT1
+		case "DXT3":
+			c.internalFormat = compressedRGBAS3TCDXT3
+		case "DXT5":
+			c.internalFormat = compressedRGBAS3TCDXT5
+		case "DXT2":
+			c.internalFormat = compressedRGBAS3TCDXT3
+			c.premultiplied = true
+		case "DXT4":
+			c.internalFormat = compressedRGBAS3TCDXT5
+			c.premultiplied = true
+		case "ATI1", "BC4U":
+			c.internalFormat = compressedRedRGTC1
+		case "ATI2", "BC5U":
+			c.internalFormat = compressedRGRGTC2
+		case "DX10":
+			var dx10 ddsHeaderDX10
+			if err := binary.Read(r, binary.LittleEndian, &dx10); err != nil {
+				return nil, err
+			}
+			if dx10.ArraySize > 1 {
+				return nil, errors.New("dds: texture arrays are not supported")
+			}
+			c.internalFormat, c.compressed, ok = dxgiFormat(dx10.DXGIFormat)
+			if !ok {
+				return nil, fmt.Errorf("dds: unsupported DXGI format %d", dx10.DXGIFormat)
+			}
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, fmt.Errorf("dds: unsupported fourCC %q", pf.FourCC[:])
+		}
+		if string(pf.FourCC[:]) != "DX10" {
+			c.compressed = true
+		}
+	case pf.Flags&ddpfRGB != 0 && pf.RGBBitCount == 32 && pf.RBitMask == 0xFF && pf.BBitMask == 0xFF0000:
+		c.internalFormat = glRGBA8
+	case pf.Flags&ddpfRGB != 0 && pf.RGBBitCount == 32 && pf.RBitMask == 0xFF0000 && pf.BBitMask == 0xFF:
+		c.internalFormat = glRGBA8
+		c.format = glBGRA
+	default:
+		return nil, errors.New("dds: unsupported pixel format")
+	}
+
+	for i := 0; i < levels; i++ {
+		level, err := readBytes(r, uint64(c.levelBytes(i)))
+		if err != nil {
+			return nil, fmt.Errorf("dds: mip level %d: %v", i, err)
+		}
+		c.levels = append(c.levels, level)
+	}
+
+	if err := c.check(); err != nil {
+		return nil, fmt.Errorf("dds: %v", err)
+	}
+	return c, nil
+}
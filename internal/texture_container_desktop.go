@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+/*
+ *	Compressed texture
+ */
+
+// LoadTexture 根据文件头识别 KTX、KTX2 或 DDS
+func LoadTexture(r io.Reader) (*Texture, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(ktxIdentifier))
+	if err != nil && len(magic) < 4 {
+		return nil, err
+	}
+	switch {
+	case bytes.Equal(magic, ktxIdentifier):
+		return LoadKTX(br)
+	case bytes.Equal(magic, ktx2Identifier):
+		return LoadKTX2(br)
+	default:
+		return LoadDDS(br)
+	}
+}
+
+func LoadKTX(r io.Reader) (*Texture, error) {
+	c, err := parseKTX(r)
+	if err != nil {
+		return nil, err
+	}
+	return newContainerTexture(c)
+}
+
+func LoadKTX2(r io.Reader) (*Texture, error) {
+	c, err := parseKTX2(r)
+	if err != nil {
+		return nil, err
+	}
+	return newContainerTexture(c)
+}
+
+func LoadDDS(r io.Reader) (*Texture, error) {
+	c, err := parseDDS(r)
+	if err != nil {
+		return nil, err
+	}
+	return newContainerTexture(c)
+}
+
+func newContainerTexture(c *textureContainer) (*Texture, error) {
+	tex := NewTexture()
+	if err := tex.uploadContainer(c); err != nil {
+		return nil, err
+	}
+	return tex, nil
+}
+
+// CompressedFormat 返回压缩纹理的 GL internalformat 和压缩块的宽、高、字节数，不是压缩纹理时 format 为 0
+func (tex *Texture) CompressedFormat() (format uint32, blockWidth, blockHeight, blockBytes int) {
+	return tex.compressed, tex.blockWidth, tex.blockHeight, tex.blockBytes
+}
+
+// 上传所有 mip 层，驱动不支持的压缩格式在 CPU 上解压成 RGBA8；容器数据保留在纹理上，上下文恢复后重新上传
+func (tex *Texture) uploadContainer(c *textureContainer) error {
+	decompress := c.compressed && !supportsCompressedFormat(c.internalFormat)
+
+	tex.width = c.width
+	tex.height = c.height
+	tex.compressed = 0
+	tex.blockWidth, tex.blockHeight, tex.blockBytes = 0, 0, 0
+	switch {
+	case c.compressed && !decompress:
+		tex.format = FormatCompressed
+		tex.compressed = c.internalFormat
+		tex.blockWidth, tex.blockHeight, tex.blockBytes, _ = compressedBlock(c.internalFormat)
+	case c.internalFormat == glSRGB8Alpha8 || isSRGBCompressed(c.internalFormat):
+		tex.format = FormatSRGB8Alpha8
+	default:
+		tex.format = FormatRGBA8
+	}
+	//资源管线导出的压缩纹理通常是非预乘的，DDS 的 DXT2、DXT4 除外
+	if c.premultiplied {
+		tex.setAlpha(AlphaPremultiplied)
+	} else {
+		tex.setAlpha(AlphaStraight)
+	}
+
+	tex.bind()
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+
+	var bytes int64
+	for level, data := range c.levels {
+		width, height := c.levelSize(level)
+		switch {
+		case decompress:
+			pix, err := decompressLevel(c.internalFormat, data, width, height)
+			if err != nil {
+				return err
+			}
+			bytes += int64(len(pix))
+			internalFormat, _, _ := textureFormat(tex.format)
+			gl.TexImage2D(gl.TEXTURE_2D, int32(level), internalFormat, int32(width), int32(height), 0,
+				gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pix))
+		case c.compressed:
+			size := c.levelBytes(level)
+			bytes += int64(size)
+			gl.CompressedTexImage2D(gl.TEXTURE_2D, int32(level), c.internalFormat, int32(width), int32(height), 0,
+				int32(size), gl.Ptr(data[:size]))
+		default:
+			gl.TexImage2D(gl.TEXTURE_2D, int32(level), int32(c.internalFormat), int32(width), int32(height), 0,
+				c.format, c.xtype, gl.Ptr(data))
+			bytes += int64(c.levelBytes(level))
+		}
+	}
+
+	tex.levels = len(c.levels)
+	tex.mipmapManual = true
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, int32(tex.levels-1))
+	if tex.levels > 1 {
+		tex.enableMipmapFilter()
+	}
+	tex.res.setBytes(bytes)
+	tex.container = c
+	checkError()
+
+	return nil
+}
+
+func isSRGBCompressed(format uint32) bool {
+	switch format {
+	case compressedSRGBS3TCDXT1, compressedSRGBAlphaS3TCDXT1, compressedSRGBAlphaS3TCDXT3, compressedSRGBAlphaS3TCDXT5,
+		compressedSRGBAlphaBPTCUnorm, compressedSRGB8ETC2, compressedSRGB8A1ETC2, compressedSRGB8Alpha8ETC2EAC:
+		return true
+	}
+	return format >= compressedSRGB8Alpha8ASTC4x4 && format < compressedSRGB8Alpha8ASTC4x4+astcBlockFormat
+}
+
+func supportsCompressedFormat(format uint32) bool {
+	switch {
+	case format >= compressedRGBAASTC4x4 && format < compressedRGBAASTC4x4+astcBlockFormat,
+		format >= compressedSRGB8Alpha8ASTC4x4 && format < compressedSRGB8Alpha8ASTC4x4+astcBlockFormat:
+		return hasExtension("GL_KHR_texture_compression_astc_ldr")
+	}
+
+	switch format {
+	case compressedRGBS3TCDXT1, compressedRGBAS3TCDXT1, compressedRGBAS3TCDXT3, compressedRGBAS3TCDXT5:
+		return hasExtension("GL_EXT_texture_compression_s3tc")
+	case compressedSRGBS3TCDXT1, compressedSRGBAlphaS3TCDXT1, compressedSRGBAlphaS3TCDXT3, compressedSRGBAlphaS3TCDXT5:
+		return hasExtension("GL_EXT_texture_compression_s3tc") && hasExtension("GL_EXT_texture_sRGB")
+	case compressedRedRGTC1, compressedSignedRedRGTC1, compressedRGRGTC2, compressedSignedRGRGTC2:
+		//OpenGL 3.0 核心功能
+		return true
+	case compressedRGBABPTCUnorm, compressedSRGBAlphaBPTCUnorm, compressedRGBBPTCSignedFloat, compressedRGBBPTCUnsigned:
+		return hasExtension("GL_ARB_texture_compression_bptc")
+	case compressedETC1RGB8:
+		return hasExtension("GL_OES_compressed_ETC1_RGB8_texture")
+	case compressedR11EAC, compressedRG11EAC, compressedRGB8ETC2, compressedSRGB8ETC2, compressedRGB8A1ETC2,
+		compressedSRGB8A1ETC2, compressedRGBA8ETC2EAC, compressedSRGB8Alpha8ETC2EAC:
+		return hasExtension("GL_ARB_ES3_compatibility")
+	default:
+		return false
+	}
+}
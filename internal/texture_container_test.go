@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func ktxBlob(order binary.ByteOrder, header ktxHeader, levels ...[]byte) []byte {
+	var b bytes.Buffer
+	b.Write(ktxIdentifier)
+	header.Endianness = 0x04030201
+	binary.Write(&b, order, &header)
+	for _, level := range levels {
+		binary.Write(&b, order, uint32(len(level)))
+		b.Write(level)
+		for b.Len()%4 != 0 {
+			b.WriteByte(0)
+		}
+	}
+	return b.Bytes()
+}
+
+func rgbaKTXHeader(width, height, levels uint32) ktxHeader {
+	return ktxHeader{
+		GLType:               glUnsignedByte,
+		GLTypeSize:           1,
+		GLFormat:             glRGBA,
+		GLInternalFormat:     glRGBA8,
+		GLBaseInternalFormat: glRGBA,
+		PixelWidth:           width,
+		PixelHeight:          height,
+		NumberOfFaces:        1,
+		NumberOfMipmapLevels: levels,
+	}
+}
+
+func dxt1KTXHeader(width, height, levels uint32) ktxHeader {
+	return ktxHeader{
+		GLInternalFormat:     compressedRGBAS3TCDXT1,
+		GLBaseInternalFormat: glRGBA,
+		PixelWidth:           width,
+		PixelHeight:          height,
+		NumberOfFaces:        1,
+		NumberOfMipmapLevels: levels,
+	}
+}
+
+func TestParseKTX(t *testing.T) {
+	pix := bytes.Repeat([]byte{1, 2, 3, 4}, 4)
+	block := make([]byte, 8)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		err    string
+		levels int
+	}{
+		{"rgba", ktxBlob(binary.LittleEndian, rgbaKTXHeader(2, 2, 1), pix), "", 1},
+		{"big endian", ktxBlob(binary.BigEndian, rgbaKTXHeader(2, 2, 1), pix), "", 1},
+		{"zero levels", ktxBlob(binary.LittleEndian, rgbaKTXHeader(2, 2, 0), pix), "", 1},
+		{"dxt1 mip chain", ktxBlob(binary.LittleEndian, dxt1KTXHeader(4, 4, 3), block, block, block), "", 3},
+		{"bad magic", append([]byte("KTX 11"), make([]byte, 64)...), "invalid identifier", 0},
+		{"truncated header", ktxBlob(binary.LittleEndian, rgbaKTXHeader(2, 2, 1))[:30], "EOF", 0},
+		{"short level", ktxBlob(binary.LittleEndian, rgbaKTXHeader(2, 2, 1), pix[:8]), "truncated", 0},
+		{"missing level", ktxBlob(binary.LittleEndian, dxt1KTXHeader(4, 4, 3), block), "mip level 1", 0},
+		{"too many levels", ktxBlob(binary.LittleEndian, rgbaKTXHeader(2, 2, 10), pix), "too many", 0},
+		{"huge size", ktxBlob(binary.LittleEndian, rgbaKTXHeader(1<<30, 1<<30, 1), pix), "invalid texture size", 0},
+		{"zero size", ktxBlob(binary.LittleEndian, rgbaKTXHeader(0, 2, 1), pix), "invalid texture size", 0},
+	}
+
+	//imageSize 远大于文件
+	huge := ktxBlob(binary.LittleEndian, rgbaKTXHeader(2, 2, 1), pix)
+	binary.LittleEndian.PutUint32(huge[len(ktxIdentifier)+13*4:], 0xFFFFFFF0)
+	tests = append(tests, struct {
+		name   string
+		data   []byte
+		err    string
+		levels int
+	}{"huge image size", huge, "unexpected EOF", 0})
+
+	for _, test := range tests {
+		c, err := parseKTX(bytes.NewReader(test.data))
+		checkParse(t, test.name, c, err, test.err, test.levels)
+	}
+}
+
+func ktx2Blob(header ktx2Header, levels ...[]byte) []byte {
+	var b bytes.Buffer
+	b.Write(ktx2Identifier)
+	binary.Write(&b, binary.LittleEndian, &header)
+	offset := uint64(b.Len() + len(levels)*24)
+	for _, level := range levels {
+		binary.Write(&b, binary.LittleEndian, ktx2Level{ByteOffset: offset, ByteLength: uint64(len(level))})
+		offset += uint64(len(level))
+	}
+	for _, level := range levels {
+		b.Write(level)
+	}
+	return b.Bytes()
+}
+
+func TestParseKTX2(t *testing.T) {
+	pix := bytes.Repeat([]byte{1, 2, 3, 4}, 4)
+	rgba := ktx2Header{VkFormat: 37, PixelWidth: 2, PixelHeight: 2, LevelCount: 1}
+	srgbBC3 := ktx2Header{VkFormat: 138, PixelWidth: 4, PixelHeight: 4, LevelCount: 1}
+
+	outOfRange := ktx2Blob(rgba, pix)
+	binary.LittleEndian.PutUint64(outOfRange[len(ktx2Identifier)+68+8:], 1<<40)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		err    string
+		levels int
+	}{
+		{"rgba", ktx2Blob(rgba, pix), "", 1},
+		{"bc3 srgb", ktx2Blob(srgbBC3, make([]byte, 16)), "", 1},
+		{"bad magic", append([]byte("KTX 20"), make([]byte, 100)...), "invalid identifier", 0},
+		{"truncated header", ktx2Blob(rgba, pix)[:40], "EOF", 0},
+		{"level out of range", outOfRange, "out of range", 0},
+		{"short level", ktx2Blob(rgba, pix[:4]), "truncated", 0},
+		{"huge level count", ktx2Blob(ktx2Header{VkFormat: 37, PixelWidth: 2, PixelHeight: 2, LevelCount: 0xFFFFFFFF}), "too many", 0},
+		{"supercompression", ktx2Blob(ktx2Header{VkFormat: 37, PixelWidth: 2, PixelHeight: 2, SupercompressionScheme: 1}), "supercompression", 0},
+		{"unsupported format", ktx2Blob(ktx2Header{VkFormat: 1, PixelWidth: 2, PixelHeight: 2}), "unsupported vkFormat", 0},
+		{"array", ktx2Blob(ktx2Header{VkFormat: 37, PixelWidth: 2, PixelHeight: 2, LayerCount: 4}), "only 2D", 0},
+	}
+	for _, test := range tests {
+		c, err := parseKTX2(bytes.NewReader(test.data))
+		checkParse(t, test.name, c, err, test.err, test.levels)
+	}
+
+	if c, err := parseKTX2(bytes.NewReader(ktx2Blob(srgbBC3, make([]byte, 16)))); err == nil {
+		if c.internalFormat != compressedSRGBAlphaS3TCDXT5 || !c.compressed {
+			t.Errorf("bc3 srgb: format 0x%X, compressed %v", c.internalFormat, c.compressed)
+		}
+	}
+}
+
+func ddsBlob(header ddsHeader, data []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("DDS ")
+	header.Size = 124
+	header.PixelFormat.Size = 32
+	binary.Write(&b, binary.LittleEndian, &header)
+	b.Write(data)
+	return b.Bytes()
+}
+
+func fourCCHeader(fourCC string, width, height, levels uint32) ddsHeader {
+	header := ddsHeader{Width: width, Height: height, MipMapCount: levels}
+	if levels > 1 {
+		header.Flags = ddsMipMapFlag
+	}
+	header.PixelFormat.Flags = ddpfFourCC
+	copy(header.PixelFormat.FourCC[:], fourCC)
+	return header
+}
+
+func TestParseDDS(t *testing.T) {
+	rgba := ddsHeader{Width: 2, Height: 2}
+	rgba.PixelFormat = ddsPixelFormat{Flags: ddpfRGB, RGBBitCount: 32, RBitMask: 0xFF, GBitMask: 0xFF00, BBitMask: 0xFF0000, ABitMask: 0xFF000000}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		err    string
+		levels int
+	}{
+		{"rgba", ddsBlob(rgba, make([]byte, 16)), "", 1},
+		{"dxt1", ddsBlob(fourCCHeader("DXT1", 4, 4, 1), make([]byte, 8)), "", 1},
+		{"dxt5 mip chain", ddsBlob(fourCCHeader("DXT5", 8, 4, 4), make([]byte, 32+16+16+16)), "", 4},
+		{"bad magic", append([]byte("DDX "), make([]byte, 200)...), "invalid magic", 0},
+		{"truncated header", ddsBlob(rgba, nil)[:60], "EOF", 0},
+		{"truncated level", ddsBlob(fourCCHeader("DXT5", 8, 4, 4), make([]byte, 40)), "mip level 1", 0},
+		{"too many levels", ddsBlob(fourCCHeader("DXT1", 4, 4, 20), make([]byte, 8*20)), "too many", 0},
+		{"huge size", ddsBlob(fourCCHeader("DXT1", 0x7FFFFFFF, 0x7FFFFFFF, 1), make([]byte, 8)), "invalid texture size", 0},
+		{"unsupported fourCC", ddsBlob(fourCCHeader("ABCD", 4, 4, 1), make([]byte, 8)), "unsupported fourCC", 0},
+	}
+	for _, test := range tests {
+		c, err := parseDDS(bytes.NewReader(test.data))
+		checkParse(t, test.name, c, err, test.err, test.levels)
+	}
+
+	//DXT2、DXT4 是预乘 alpha 的
+	for fourCC, premultiplied := range map[string]bool{"DXT2": true, "DXT3": false, "DXT4": true, "DXT5": false} {
+		c, err := parseDDS(bytes.NewReader(ddsBlob(fourCCHeader(fourCC, 4, 4, 1), make([]byte, 16))))
+		if err != nil {
+			t.Errorf("%s: %v", fourCC, err)
+			continue
+		}
+		if c.premultiplied != premultiplied {
+			t.Errorf("%s: premultiplied = %v, want %v", fourCC, c.premultiplied, premultiplied)
+		}
+	}
+}
+
+func checkParse(t *testing.T, name string, c *textureContainer, err error, wantErr string, levels int) {
+	t.Helper()
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%s: error = %v, want %q", name, err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("%s: %v", name, err)
+		return
+	}
+	if len(c.levels) != levels {
+		t.Errorf("%s: %d levels, want %d", name, len(c.levels), levels)
+	}
+	for i, level := range c.levels {
+		if len(level) != c.levelBytes(i) {
+			t.Errorf("%s: level %d has %d bytes, want %d", name, i, len(level), c.levelBytes(i))
+		}
+	}
+}
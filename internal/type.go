@@ -1,6 +1,9 @@
 package internal
 
-import "math"
+import (
+	"fmt"
+	"math"
+)
 
 type AttrType int
 
@@ -68,3 +71,237 @@ const (
 	DepthTest   CapType = 0x0B71 //gl.DEPTH_TEST
 	ScissorTest CapType = 0x0C11 //gl.SCISSOR_TEST
 )
+
+type FilterMode int32
+
+const (
+	FilterNearest              FilterMode = 0x2600 //gl.NEAREST
+	FilterLinear               FilterMode = 0x2601 //gl.LINEAR
+	FilterNearestMipmapNearest FilterMode = 0x2700 //gl.NEAREST_MIPMAP_NEAREST
+	FilterLinearMipmapNearest  FilterMode = 0x2701 //gl.LINEAR_MIPMAP_NEAREST
+	FilterNearestMipmapLinear  FilterMode = 0x2702 //gl.NEAREST_MIPMAP_LINEAR
+	FilterLinearMipmapLinear   FilterMode = 0x2703 //gl.LINEAR_MIPMAP_LINEAR
+)
+
+// 没有 mipmap 时使用带 mipmap 的过滤方式会导致纹理不完整，退回到对应的基础过滤
+func (f FilterMode) withoutMipmap() FilterMode {
+	switch f {
+	case FilterNearestMipmapNearest, FilterNearestMipmapLinear:
+		return FilterNearest
+	case FilterLinearMipmapNearest, FilterLinearMipmapLinear:
+		return FilterLinear
+	default:
+		return f
+	}
+}
+
+type WrapMode int32
+
+const (
+	WrapClamp  WrapMode = 0x812F //gl.CLAMP_TO_EDGE
+	WrapBorder WrapMode = 0x812D //gl.CLAMP_TO_BORDER
+	WrapRepeat WrapMode = 0x2901 //gl.REPEAT
+	WrapMirror WrapMode = 0x8370 //gl.MIRRORED_REPEAT
+)
+
+// SamplerOptions 纹理采样方式，为零的过滤和环绕方式使用 DefaultSampler 的设置，
+// 例如只设置 MinFilter、MagFilter 时仍然是 WrapClamp；MaxAnisotropy 小于等于 1 时不开启各向异性过滤
+type SamplerOptions struct {
+	MinFilter     FilterMode
+	MagFilter     FilterMode
+	WrapS         WrapMode
+	WrapT         WrapMode
+	BorderColor   [4]float32
+	MaxAnisotropy float32
+}
+
+var DefaultSampler = SamplerOptions{
+	MinFilter: FilterLinear,
+	MagFilter: FilterLinear,
+	WrapS:     WrapClamp,
+	WrapT:     WrapClamp,
+}
+
+// 零值不是合法的 GL 枚举，替换成 DefaultSampler 的设置
+func (opts SamplerOptions) withDefaults() SamplerOptions {
+	if opts.MinFilter == 0 {
+		opts.MinFilter = DefaultSampler.MinFilter
+	}
+	if opts.MagFilter == 0 {
+		opts.MagFilter = DefaultSampler.MagFilter
+	}
+	if opts.WrapS == 0 {
+		opts.WrapS = DefaultSampler.WrapS
+	}
+	if opts.WrapT == 0 {
+		opts.WrapT = DefaultSampler.WrapT
+	}
+	return opts
+}
+
+// TextureFormat 纹理的像素格式，零值为 RGBA8
+type TextureFormat int
+
+const (
+	FormatRGBA8 TextureFormat = iota
+	FormatR8
+	FormatRG8
+	FormatRGB8
+	FormatSRGB8Alpha8
+	FormatRGBA16F
+	FormatRGBA32F
+	FormatR32F
+	FormatDepth16
+	FormatDepth24
+	FormatDepth32F
+	FormatDepth24Stencil8
+	// LoadTexture 载入的 GPU 压缩格式，具体格式见 Texture.CompressedFormat，不能用 Upload 更新
+	FormatCompressed
+)
+
+func (f TextureFormat) Channels() int {
+	switch f {
+	case FormatR8, FormatR32F, FormatDepth16, FormatDepth24, FormatDepth32F, FormatDepth24Stencil8:
+		return 1
+	case FormatRG8:
+		return 2
+	case FormatRGB8:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// BytesPerPixel 显存中每个像素的字节数（估算值）
+func (f TextureFormat) BytesPerPixel() int {
+	switch f {
+	case FormatR8:
+		return 1
+	case FormatRG8, FormatDepth16:
+		return 2
+	case FormatRGB8:
+		return 3
+	case FormatRGBA8, FormatSRGB8Alpha8, FormatR32F, FormatDepth24, FormatDepth32F, FormatDepth24Stencil8:
+		return 4
+	case FormatRGBA16F:
+		return 8
+	case FormatRGBA32F:
+		return 16
+	case FormatCompressed:
+		//按压缩块计算，不能按像素估算
+		return 0
+	default:
+		panic("bytes per pixel of texture format: invalid format")
+	}
+}
+
+func (f TextureFormat) IsDepth() bool {
+	return f >= FormatDepth16 && f <= FormatDepth24Stencil8
+}
+
+func (f TextureFormat) IsFloat() bool {
+	switch f {
+	case FormatRGBA16F, FormatRGBA32F, FormatR32F, FormatDepth32F:
+		return true
+	default:
+		return false
+	}
+}
+
+// AlphaMode 纹理数据的 alpha 是否预乘，image.RGBA 为预乘，image.NRGBA 为非预乘
+type AlphaMode int
+
+const (
+	AlphaPremultiplied AlphaMode = iota
+	AlphaStraight
+)
+
+// BlendMode 混合预设，根据槽位 0 上纹理的 AlphaMode 自动选择混合因子；
+// BlendModeMultiply、BlendModeScreen 只支持预乘的颜色，非预乘的纹理需要着色器输出 color.rgb * color.a
+type BlendMode int
+
+const (
+	BlendModeNormal BlendMode = iota
+	BlendModeAdd
+	BlendModeMultiply
+	BlendModeScreen
+)
+
+func (mode BlendMode) factors(alpha AlphaMode) (BlendFormat, BlendFormat) {
+	src := BlendOne
+	if alpha == AlphaStraight {
+		src = BlendSrcAlpha
+	}
+	switch mode {
+	case BlendModeAdd:
+		return src, BlendOne
+	case BlendModeMultiply:
+		//src*dst + dst*(1-a) 要求 src 是预乘的，非预乘数据没有对应的混合因子，
+		//需要在着色器中预乘后输出，commit 会给出警告
+		return BlendDstColor, BlendOneMinusSrcAlpha
+	case BlendModeScreen:
+		//src + dst*(1-src)，同样要求 src 是预乘的
+		return BlendOne, BlendOneMinusSrcColor
+	default:
+		return src, BlendOneMinusSrcAlpha
+	}
+}
+
+// 混合公式要求预乘颜色的模式
+func (mode BlendMode) premultipliedOnly() bool {
+	return mode == BlendModeMultiply || mode == BlendModeScreen
+}
+
+func (mode BlendMode) String() string {
+	switch mode {
+	case BlendModeNormal:
+		return "normal"
+	case BlendModeAdd:
+		return "add"
+	case BlendModeMultiply:
+		return "multiply"
+	case BlendModeScreen:
+		return "screen"
+	default:
+		return fmt.Sprintf("BlendMode(%d)", int(mode))
+	}
+}
+
+// DepthAttachment 渲染目标的深度（模板）附件类型
+type DepthAttachment int
+
+const (
+	DepthNone DepthAttachment = iota
+	// 不能采样的 renderbuffer
+	DepthRenderbuffer
+	// 可以作为纹理采样，例如阴影贴图
+	DepthTexture
+)
+
+// TargetOptions 渲染目标的附件配置，Colors 为空时只有一个 RGBA8 颜色附件；
+// Stencil 为 true 时深度和模板合并为 Depth24Stencil8，Depth 为 DepthNone 时按 DepthRenderbuffer 处理；
+// Samples 大于 1 时所有附件都是多重采样的 renderbuffer，需要 Resolve 到普通目标后才能采样
+type TargetOptions struct {
+	Colors      []TextureFormat
+	Depth       DepthAttachment
+	DepthFormat TextureFormat
+	Stencil     bool
+	Samples     int
+}
+
+func (opts TargetOptions) colorFormats() []TextureFormat {
+	if len(opts.Colors) == 0 {
+		return []TextureFormat{FormatRGBA8}
+	}
+	return opts.Colors
+}
+
+func (opts TargetOptions) depthFormat() TextureFormat {
+	if opts.Stencil {
+		return FormatDepth24Stencil8
+	}
+	if opts.DepthFormat.IsDepth() {
+		return opts.DepthFormat
+	}
+	return FormatDepth24
+}
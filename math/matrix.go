@@ -144,6 +144,7 @@ func (m *Matrix) SetTransform(x, y, pivotX, pivotY, scaleX, scaleY, rotation, sk
 	m.c = nsx*a + cx*c
 	m.d = nsx*b + cx*d
 
-	m.tx = x + (pivotX*a + pivotY*c)
-	m.ty = y + (pivotX*b + pivotY*d)
+	//先把 pivot 移到原点再变换，所以是减去变换后的 pivot
+	m.tx = x - (pivotX*m.a + pivotY*m.c)
+	m.ty = y - (pivotX*m.b + pivotY*m.d)
 }
@@ -0,0 +1,46 @@
+package math
+
+import (
+	stdmath "math"
+	"testing"
+)
+
+// Sin、Cos 查表计算，精度有限
+func near(a, b float32) bool {
+	return stdmath.Abs(float64(a-b)) < 1e-2
+}
+
+func TestSetTransformPivot(t *testing.T) {
+	tests := []struct {
+		name                                         string
+		x, y, pivotX, pivotY, scaleX, scaleY, rotate float32
+		px, py                                       float32 //局部坐标
+		wantX, wantY                                 float32
+	}{
+		{"pivot at position", 100, 50, 10, 20, 2, 3, 0, 10, 20, 100, 50},
+		{"scaled origin", 100, 50, 10, 20, 2, 3, 0, 0, 0, 80, -10},
+		{"rotated around pivot", 0, 0, 10, 0, 1, 1, Pi / 2, 20, 0, 0, 10},
+		{"rotated and scaled", 5, 5, 4, 2, 2, 2, Pi, 5, 2, 3, 5},
+	}
+	for _, test := range tests {
+		var m Matrix
+		m.SetTransform(test.x, test.y, test.pivotX, test.pivotY, test.scaleX, test.scaleY, test.rotate, 0, 0)
+		x, y := m.Apply(test.px, test.py)
+		if !near(x, test.wantX) || !near(y, test.wantY) {
+			t.Errorf("%s: Apply(%v, %v) = (%v, %v), want (%v, %v)", test.name, test.px, test.py, x, y, test.wantX, test.wantY)
+		}
+		lx, ly := m.ApplyInverse(x, y)
+		if !near(lx, test.px) || !near(ly, test.py) {
+			t.Errorf("%s: ApplyInverse = (%v, %v), want (%v, %v)", test.name, lx, ly, test.px, test.py)
+		}
+	}
+}
+
+func TestSetTransformSkewPivot(t *testing.T) {
+	//任何缩放、旋转、斜切下 pivot 都落在 position 上
+	var m Matrix
+	m.SetTransform(30, -7, 12, 9, 1.5, 0.5, 0.7, 0.3, -0.2)
+	if x, y := m.Apply(12, 9); !near(x, 30) || !near(y, -7) {
+		t.Errorf("pivot maps to (%v, %v), want (30, -7)", x, y)
+	}
+}